@@ -0,0 +1,220 @@
+package timed
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/anthonynsimon/bild/parallel"
+)
+
+// Blender crossfades images into output buffers that are reused from one
+// frame to the next, with specialized code for *image.RGBA and
+// *image.YCbCr images of the same size, where the rows are blended in
+// parallel. Other images are crossfaded with Crossfade. The images that
+// are returned by Blend are only valid until the next call.
+type Blender struct {
+	mut   sync.Mutex
+	rgba  *image.RGBA
+	ycbcr *image.YCbCr
+}
+
+// DefaultBlender is the blender that is used by the event loop and by the frame cache
+var DefaultBlender = &Blender{}
+
+var (
+	linearToSRGB8Once  sync.Once
+	linearToSRGB8Table []float32
+)
+
+// linearToSRGB8 returns a table that maps 16-bit linear light to sRGB
+// values from 0 to 255, without rounding, so that they can be dithered.
+// The table is created the first time it is needed.
+func linearToSRGB8() []float32 {
+	linearToSRGB8Once.Do(func() {
+		linearToSRGB8Table = make([]float32, 65536)
+		for i := range linearToSRGB8Table {
+			linearToSRGB8Table[i] = float32(linearToSRGB(float64(i)/65535) * 255)
+		}
+	})
+	return linearToSRGB8Table
+}
+
+// mixFixed returns the ratio as a 16-bit fixed point number
+func mixFixed(ratio float64) uint32 {
+	return uint32(math.Max(0, math.Min(1, ratio))*65536 + 0.5)
+}
+
+// mixBytes mixes two byte slices of the same length into dst, in parallel,
+// where r is the ratio as a 16-bit fixed point number
+func mixBytes(dst, a, b []uint8, r uint32) {
+	parallel.Line(len(dst), func(start, end int) {
+		dst, a, b := dst[start:end], a[start:end], b[start:end]
+		for i := range dst {
+			dst[i] = uint8((uint32(a[i])*(65536-r) + uint32(b[i])*r + 32768) >> 16)
+		}
+	})
+}
+
+// sameRGBA checks if two RGBA images have the same layout in memory
+func sameRGBA(a, b *image.RGBA) bool {
+	return a.Rect == b.Rect && a.Stride == b.Stride && len(a.Pix) == len(b.Pix)
+}
+
+// sameYCbCr checks if two YCbCr images have the same layout in memory
+func sameYCbCr(a, b *image.YCbCr) bool {
+	return a.Rect == b.Rect && a.SubsampleRatio == b.SubsampleRatio &&
+		a.YStride == b.YStride && a.CStride == b.CStride &&
+		len(a.Y) == len(b.Y) && len(a.Cb) == len(b.Cb) && len(a.Cr) == len(b.Cr)
+}
+
+// rgbaLike returns an RGBA image with the same layout as the given one,
+// reusing the previous output buffer if possible
+func (bl *Blender) rgbaLike(a *image.RGBA) *image.RGBA {
+	if bl.rgba == nil || !sameRGBA(bl.rgba, a) {
+		bl.rgba = &image.RGBA{Pix: make([]uint8, len(a.Pix)), Stride: a.Stride, Rect: a.Rect}
+	}
+	return bl.rgba
+}
+
+// ycbcrLike returns a YCbCr image with the same layout as the given one,
+// reusing the previous output buffer if possible
+func (bl *Blender) ycbcrLike(a *image.YCbCr) *image.YCbCr {
+	if bl.ycbcr == nil || !sameYCbCr(bl.ycbcr, a) {
+		bl.ycbcr = &image.YCbCr{
+			Y:              make([]uint8, len(a.Y)),
+			Cb:             make([]uint8, len(a.Cb)),
+			Cr:             make([]uint8, len(a.Cr)),
+			YStride:        a.YStride,
+			CStride:        a.CStride,
+			SubsampleRatio: a.SubsampleRatio,
+			Rect:           a.Rect,
+		}
+	}
+	return bl.ycbcr
+}
+
+// rgbaFor returns an RGBA image with the given bounds, reusing the
+// previous output buffer if possible
+func (bl *Blender) rgbaFor(rect image.Rectangle) *image.RGBA {
+	if bl.rgba == nil || bl.rgba.Rect != rect || bl.rgba.Stride != rect.Dx()*4 {
+		bl.rgba = image.NewRGBA(rect)
+	}
+	return bl.rgba
+}
+
+// rowReader returns a function that reads a row of an RGBA or YCbCr image
+// as 8-bit RGBA values, or nil for other images
+func rowReader(img image.Image) func(y int, row []uint8) {
+	switch v := img.(type) {
+	case *image.RGBA:
+		return func(y int, row []uint8) {
+			i := v.PixOffset(v.Rect.Min.X, y)
+			copy(row, v.Pix[i:i+v.Rect.Dx()*4])
+		}
+	case *image.YCbCr:
+		return func(y int, row []uint8) {
+			for x, j := v.Rect.Min.X, 0; x < v.Rect.Max.X; x, j = x+1, j+4 {
+				yi, ci := v.YOffset(x, y), v.COffset(x, y)
+				row[j], row[j+1], row[j+2] = color.YCbCrToRGB(v.Y[yi], v.Cb[ci], v.Cr[ci])
+				row[j+3] = 255
+			}
+		}
+	}
+	return nil
+}
+
+// blendLinear crossfades two images of the same size in linear light, and
+// writes the result as 8-bit RGBA values with the given dithering method
+func (bl *Blender) blendLinear(from, to image.Image, ratio float64, dither string) image.Image {
+	readA, readB := rowReader(from), rowReader(to)
+	rect := from.Bounds()
+	dst := bl.rgbaFor(rect)
+	table := linearToSRGB8()
+	w := rect.Dx()
+	parallel.Line(rect.Dy(), func(start, end int) {
+		rowA, rowB := make([]uint8, w*4), make([]uint8, w*4)
+		for y := rect.Min.Y + start; y < rect.Min.Y+end; y++ {
+			readA(y, rowA)
+			readB(y, rowB)
+			out := dst.Pix[dst.PixOffset(rect.Min.X, y):]
+			for x, i := 0, 0; x < w; x, i = x+1, i+4 {
+				threshold := float32(ditherThreshold(dither, rect.Min.X+x, y))
+				for c := 0; c < 3; c++ {
+					la, lb := srgbToLinearTable[rowA[i+c]], srgbToLinearTable[rowB[i+c]]
+					v := table[int((la+(lb-la)*ratio)*65535+0.5)] + threshold
+					if v >= 255 {
+						out[i+c] = 255
+					} else {
+						out[i+c] = uint8(v)
+					}
+				}
+				aa, ab := float64(rowA[i+3]), float64(rowB[i+3])
+				out[i+3] = uint8(math.Min(255, aa+(ab-aa)*ratio+float64(threshold)))
+			}
+		}
+	})
+	return dst
+}
+
+// mixSRGB mixes the byte values of two RGBA or YCbCr images with the same
+// layout into the output buffer, or returns nil for other images
+func (bl *Blender) mixSRGB(from, to image.Image, ratio float64) image.Image {
+	switch a := from.(type) {
+	case *image.RGBA:
+		if b, ok := to.(*image.RGBA); ok && sameRGBA(a, b) {
+			dst := bl.rgbaLike(a)
+			mixBytes(dst.Pix, a.Pix, b.Pix, mixFixed(ratio))
+			return dst
+		}
+	case *image.YCbCr:
+		// YCbCr is a linear transformation of the sRGB values, so the planes can be mixed directly
+		if b, ok := to.(*image.YCbCr); ok && sameYCbCr(a, b) {
+			dst := bl.ycbcrLike(a)
+			r := mixFixed(ratio)
+			mixBytes(dst.Y, a.Y, b.Y, r)
+			mixBytes(dst.Cb, a.Cb, b.Cb, r)
+			mixBytes(dst.Cr, a.Cr, b.Cr, r)
+			return dst
+		}
+	}
+	return nil
+}
+
+// Blend crossfades two images by the given ratio in the given color space,
+// like Crossfade, and returns an 8-bit image that has been dithered with
+// the given dithering method, if needed. The returned image is reused by
+// the next call to Blend or Encode.
+func (bl *Blender) Blend(from, to image.Image, ratio float64, colorSpace, dither string) image.Image {
+	bl.mut.Lock()
+	defer bl.mut.Unlock()
+	return bl.blend(from, to, ratio, colorSpace, dither)
+}
+
+// blend crossfades two images, while the mutex is locked
+func (bl *Blender) blend(from, to image.Image, ratio float64, colorSpace, dither string) image.Image {
+	ratio = math.Max(0, math.Min(1, ratio))
+	switch colorSpace {
+	case SRGB:
+		if img := bl.mixSRGB(from, to, ratio); img != nil {
+			return img
+		}
+	case Oklab:
+		// Crossfaded by Crossfade below
+	default:
+		if rowReader(from) != nil && rowReader(to) != nil && from.Bounds() == to.Bounds() {
+			return bl.blendLinear(from, to, ratio, dither)
+		}
+	}
+	return Dither(Crossfade(from, to, ratio, colorSpace), dither)
+}
+
+// Encode crossfades two images, like Blend, and encodes the result in the
+// given frame format directly to w, while the output buffer is in use
+func (bl *Blender) Encode(w io.Writer, from, to image.Image, ratio float64, colorSpace, dither string, ff *FrameFormat) error {
+	bl.mut.Lock()
+	defer bl.mut.Unlock()
+	return ff.Encoder()(w, bl.blend(from, to, ratio, colorSpace, dither))
+}
@@ -0,0 +1,164 @@
+package timed
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"testing"
+
+	"github.com/anthonynsimon/bild/blend"
+)
+
+// gradient returns an RGBA image with a horizontal gradient of the given color
+func gradient(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint32(x * 255 / (w - 1))
+			img.SetRGBA(x, y, color.RGBA{uint8(uint32(c.R) * v / 255), uint8(uint32(c.G) * v / 255), uint8(uint32(c.B) * v / 255), 255})
+		}
+	}
+	return img
+}
+
+// toYCbCr converts an image to a YCbCr image with 4:2:0 subsampling
+func toYCbCr(img image.Image) *image.YCbCr {
+	b := img.Bounds()
+	dst := image.NewYCbCr(b, image.YCbCrSubsampleRatio420)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(bl>>8))
+			dst.Y[dst.YOffset(x, y)] = yy
+			dst.Cb[dst.COffset(x, y)] = cb
+			dst.Cr[dst.COffset(x, y)] = cr
+		}
+	}
+	return dst
+}
+
+// maxDifference returns the largest difference between the 8-bit color
+// values of two images of the same size
+func maxDifference(a, b image.Image) int {
+	largest := 0
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca, cb := color.RGBAModel.Convert(a.At(x, y)).(color.RGBA), color.RGBAModel.Convert(b.At(x, y)).(color.RGBA)
+			for _, d := range []int{int(ca.R) - int(cb.R), int(ca.G) - int(cb.G), int(ca.B) - int(cb.B), int(ca.A) - int(cb.A)} {
+				if d < 0 {
+					d = -d
+				}
+				if d > largest {
+					largest = d
+				}
+			}
+		}
+	}
+	return largest
+}
+
+func TestBlender(t *testing.T) {
+	from, to := gradient(64, 16, color.RGBA{255, 128, 0, 255}), gradient(64, 16, color.RGBA{0, 64, 255, 255})
+	bl := &Blender{}
+
+	// The blender gives the same results as Crossfade
+	for _, colorSpace := range []string{SRGB, LinearLight, Oklab} {
+		for _, ratio := range []float64{0, 0.3, 0.5, 1} {
+			expected := Dither(Crossfade(from, to, ratio, colorSpace), DitherNone)
+			if d := maxDifference(bl.Blend(from, to, ratio, colorSpace, DitherNone), expected); d > 1 {
+				t.Errorf("%s at %.1f: expected the same colors as Crossfade, differs by %d", colorSpace, ratio, d)
+			}
+		}
+	}
+
+	// The output buffer is reused
+	first := bl.Blend(from, to, 0.2, LinearLight, DitherOrdered)
+	if second := bl.Blend(from, to, 0.4, LinearLight, DitherOrdered); second != first {
+		t.Error("expected the output buffer to be reused")
+	}
+
+	// YCbCr images are mixed as they are, and may also be crossfaded in linear light
+	yFrom, yTo := toYCbCr(from), toYCbCr(to)
+	img := bl.Blend(yFrom, yTo, 0.5, SRGB, DitherNone)
+	if _, ok := img.(*image.YCbCr); !ok {
+		t.Errorf("expected a YCbCr image, got %T", img)
+	}
+	if d := maxDifference(img, Crossfade(yFrom, yTo, 0.5, SRGB)); d > 2 {
+		t.Errorf("expected the mixed YCbCr image to look like the crossfade, differs by %d", d)
+	}
+	if d := maxDifference(bl.Blend(yFrom, yTo, 0.5, LinearLight, DitherNone), Dither(Crossfade(yFrom, yTo, 0.5, LinearLight), DitherNone)); d > 1 {
+		t.Errorf("expected YCbCr images to be crossfaded in linear light, differs by %d", d)
+	}
+
+	// Images of different sizes are crossfaded by Crossfade
+	if b := bl.Blend(from, uniform(255), 0.5, LinearLight, DitherNone).Bounds(); b != from.Bounds() {
+		t.Errorf("expected the size of the \"from\" image, got %v", b)
+	}
+}
+
+// The wallpapers are 4K, which is a common size
+func benchmarkImages() (*image.RGBA, *image.RGBA) {
+	return gradient(3840, 2160, color.RGBA{255, 128, 0, 255}), gradient(3840, 2160, color.RGBA{0, 64, 255, 255})
+}
+
+func BenchmarkOpacity(b *testing.B) {
+	from, to := benchmarkImages()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		blend.Opacity(from, to, 0.5)
+	}
+}
+
+func BenchmarkBlendRGBA(b *testing.B) {
+	from, to := benchmarkImages()
+	bl := &Blender{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bl.Blend(from, to, 0.5, SRGB, DitherNone)
+	}
+}
+
+func BenchmarkBlendYCbCr(b *testing.B) {
+	from, to := benchmarkImages()
+	yFrom, yTo := toYCbCr(from), toYCbCr(to)
+	bl := &Blender{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bl.Blend(yFrom, yTo, 0.5, SRGB, DitherNone)
+	}
+}
+
+func BenchmarkCrossfadeLinearLight(b *testing.B) {
+	from, to := benchmarkImages()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Dither(Crossfade(from, to, 0.5, LinearLight), DitherNoise)
+	}
+}
+
+func BenchmarkBlendLinearLight(b *testing.B) {
+	from, to := benchmarkImages()
+	bl := &Blender{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bl.Blend(from, to, 0.5, LinearLight, DitherNoise)
+	}
+}
+
+func BenchmarkBlendEncode(b *testing.B) {
+	from, to := benchmarkImages()
+	bl := &Blender{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := bl.Encode(ioutil.Discard, from, to, 0.5, LinearLight, DitherNone, DefaultFrameFormat); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
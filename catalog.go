@@ -0,0 +1,138 @@
+package timed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+)
+
+// Handle the GNOME wallpaper catalog XML format, as found in
+// /usr/share/gnome-background-properties/
+
+type GCatalog struct {
+	XMLName    xml.Name         `xml:"wallpapers"`
+	Wallpapers []*GCatalogEntry `xml:"wallpaper"`
+}
+
+// GName is a name in a GNOME wallpaper catalog, where Lang is the locale
+// of the name, or "" for the untranslated name
+type GName struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type GCatalogEntry struct {
+	XMLName      xml.Name `xml:"wallpaper"`
+	Deleted      string   `xml:"deleted,attr,omitempty"`
+	Names        []GName  `xml:"name"`
+	Filename     string   `xml:"filename"`
+	FilenameDark string   `xml:"filename-dark,omitempty"`
+	Options      string   `xml:"options,omitempty"`
+	ShadeType    string   `xml:"shade_type,omitempty"`
+	PColor       string   `xml:"pcolor,omitempty"`
+	SColor       string   `xml:"scolor,omitempty"`
+	Artist       string   `xml:"artist,omitempty"`
+}
+
+// ParseCatalog parses a GNOME wallpaper catalog XML file
+func ParseCatalog(filename string) (*GCatalog, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var catalog GCatalog
+	if err = xml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("could not parse %s as XML: error: %s", filename, err)
+	}
+	return &catalog, nil
+}
+
+// Find returns the catalog entry for the given filename, or nil
+func (gc *GCatalog) Find(filename string) *GCatalogEntry {
+	for _, entry := range gc.Wallpapers {
+		if entry.Filename == filename || entry.FilenameDark == filename {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (gc *GCatalog) String() string {
+	data, err := xml.MarshalIndent(gc, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return xml.Header + "<!DOCTYPE wallpapers SYSTEM \"gnome-wp-list.dtd\">\n" + string(bytes.Trim(data, " \n"))
+}
+
+// Name returns the untranslated name of the catalog entry
+func (entry *GCatalogEntry) Name() string {
+	for _, name := range entry.Names {
+		if name.Lang == "" {
+			return name.Value
+		}
+	}
+	return ""
+}
+
+// metadataFields returns the fields of the catalog entry that are kept as
+// metadata of timed wallpapers, by the name of the metadata field
+func (entry *GCatalogEntry) metadataFields() map[string]*string {
+	return map[string]*string{
+		"author":                &entry.Artist,
+		"x-gnome-filename-dark": &entry.FilenameDark,
+		"x-gnome-options":       &entry.Options,
+		"x-gnome-shade-type":    &entry.ShadeType,
+		"x-gnome-pcolor":        &entry.PColor,
+		"x-gnome-scolor":        &entry.SColor,
+	}
+}
+
+// CatalogEntry returns an entry for a GNOME wallpaper catalog, for this
+// timed wallpaper at the given path. The localized names are written with
+// xml:lang attributes, the author is written as the artist, and the
+// "x-gnome-" metadata fields are written as the other catalog fields. The
+// options are "zoom" if they are not set.
+func (fw *FatWallpaper) CatalogEntry(path string) *GCatalogEntry {
+	names := []GName{{Value: fw.Name}}
+	for _, locale := range fw.locales() {
+		names = append(names, GName{Lang: locale, Value: fw.Names[locale]})
+	}
+	entry := &GCatalogEntry{
+		Deleted:  "false",
+		Names:    names,
+		Filename: path,
+	}
+	for key, field := range entry.metadataFields() {
+		*field = fw.Meta(key)
+	}
+	if entry.Options == "" {
+		entry.Options = "zoom"
+	}
+	return entry
+}
+
+// UseCatalogEntry sets the name, the localized names and the metadata of
+// this timed wallpaper from an entry in a GNOME wallpaper catalog. The
+// artist is used as the author, and the other catalog fields are kept as
+// "x-gnome-" metadata fields, like "x-gnome-pcolor", unless they are
+// already set. Returns an error if a field can not be used as metadata.
+func (fw *FatWallpaper) UseCatalogEntry(entry *GCatalogEntry) error {
+	for _, name := range entry.Names {
+		if name.Lang == "" {
+			fw.Name = name.Value
+		} else {
+			fw.SetNameFor(name.Lang, name.Value)
+		}
+	}
+	for key, field := range entry.metadataFields() {
+		if *field == "" || fw.Meta(key) != "" {
+			continue
+		}
+		if err := fw.SetMeta(key, *field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
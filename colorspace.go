@@ -0,0 +1,136 @@
+package timed
+
+import (
+	"image"
+	"math"
+
+	"github.com/anthonynsimon/bild/blend"
+	"github.com/anthonynsimon/bild/fcolor"
+	"github.com/anthonynsimon/bild/parallel"
+)
+
+// The color spaces that images can be crossfaded in
+const (
+	SRGB        = "srgb"         // the byte values are mixed directly, which darkens the midpoints
+	LinearLight = "linear-light" // the default, where the light intensities are mixed
+	Oklab       = "oklab"        // a perceptual color space, where the hues are kept stable
+)
+
+// isColorSpace checks if the given transition option is a color space
+func isColorSpace(option string) bool {
+	return option == SRGB || option == LinearLight || option == Oklab
+}
+
+// srgbToLinearTable maps sRGB byte values to linear light, from 0 to 1
+var srgbToLinearTable = func() [256]float64 {
+	var table [256]float64
+	for i := range table {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			table[i] = c / 12.92
+		} else {
+			table[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+// linearToSRGB converts linear light to an sRGB value, both from 0 to 1
+func linearToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// toOklab converts a color in linear light to Oklab
+func toOklab(c fcolor.RGBAF64) fcolor.RGBAF64 {
+	l := math.Cbrt(0.4122214708*c.R + 0.5363325363*c.G + 0.0514459929*c.B)
+	m := math.Cbrt(0.2119034982*c.R + 0.6806995451*c.G + 0.1073969566*c.B)
+	s := math.Cbrt(0.0883024619*c.R + 0.2817188376*c.G + 0.6299787005*c.B)
+	return fcolor.RGBAF64{
+		R: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		G: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+		A: c.A,
+	}
+}
+
+// fromOklab converts a color in Oklab to linear light
+func fromOklab(c fcolor.RGBAF64) fcolor.RGBAF64 {
+	l := c.R + 0.3963377774*c.G + 0.2158037573*c.B
+	m := c.R - 0.1055613458*c.G - 0.0638541728*c.B
+	s := c.R - 0.0894841775*c.G - 1.2914855480*c.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return fcolor.RGBAF64{
+		R: 4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		G: -1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		B: -0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+		A: c.A,
+	}
+}
+
+// mix mixes two colors by the given ratio
+func mix(a, b fcolor.RGBAF64, ratio float64) fcolor.RGBAF64 {
+	return fcolor.RGBAF64{
+		R: a.R + (b.R-a.R)*ratio,
+		G: a.G + (b.G-a.G)*ratio,
+		B: a.B + (b.B-a.B)*ratio,
+		A: a.A + (b.A-a.A)*ratio,
+	}
+}
+
+// setWord sets a 16-bit value in the pixel data of an RGBA64 image, from a
+// value from 0 to 1
+func setWord(pix []uint8, i int, c float64) {
+	v := uint16(math.Max(0, math.Min(1, c))*65535 + 0.5)
+	pix[i], pix[i+1] = uint8(v>>8), uint8(v)
+}
+
+// Crossfade mixes two images by the given ratio, from 0 for only the "from"
+// image to 1 for only the "to" image, in the given color space. Unknown
+// color spaces are treated as linear light. The result has the size of the
+// "from" image. Images that are crossfaded in linear light or Oklab are
+// returned as 16-bit images, which may be dithered when they are written.
+// See Blender for crossfading frame after frame without new allocations.
+func Crossfade(from, to image.Image, ratio float64, colorSpace string) image.Image {
+	ratio = math.Max(0, math.Min(1, ratio))
+	if colorSpace == SRGB {
+		if img := (&Blender{}).mixSRGB(from, to, ratio); img != nil {
+			return img
+		}
+		return blend.Opacity(from, to, ratio)
+	}
+	a, b := transitionCanvas(from, to)
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	dst := image.NewRGBA64(a.Rect)
+	parallel.Line(h, func(start, end int) {
+		for y := start; y < end; y++ {
+			for i, j := y*a.Stride, y*dst.Stride; i < y*a.Stride+w*4; i, j = i+4, j+8 {
+				ca := fcolor.RGBAF64{R: srgbToLinearTable[a.Pix[i]], G: srgbToLinearTable[a.Pix[i+1]], B: srgbToLinearTable[a.Pix[i+2]], A: float64(a.Pix[i+3]) / 255}
+				cb := fcolor.RGBAF64{R: srgbToLinearTable[b.Pix[i]], G: srgbToLinearTable[b.Pix[i+1]], B: srgbToLinearTable[b.Pix[i+2]], A: float64(b.Pix[i+3]) / 255}
+				var c fcolor.RGBAF64
+				if colorSpace == Oklab {
+					c = fromOklab(mix(toOklab(ca), toOklab(cb), ratio))
+				} else {
+					c = mix(ca, cb, ratio)
+				}
+				setWord(dst.Pix, j, linearToSRGB(c.R))
+				setWord(dst.Pix, j+2, linearToSRGB(c.G))
+				setWord(dst.Pix, j+4, linearToSRGB(c.B))
+				setWord(dst.Pix, j+6, c.A)
+			}
+		}
+	})
+	return dst
+}
+
+// usesColorSpaces checks if any of the transitions has a color space
+func (fw *FatWallpaper) usesColorSpaces() bool {
+	for _, t := range fw.Transitions {
+		if t.ColorSpace != "" {
+			return true
+		}
+	}
+	return false
+}
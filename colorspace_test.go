@@ -0,0 +1,52 @@
+package timed
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestCrossfade(t *testing.T) {
+	black, white := uniform(0), uniform(255)
+	for colorSpace, expected := range map[string]uint8{SRGB: 127, LinearLight: 188, Oklab: 99} {
+		r, _, _, _ := Crossfade(black, white, 0.5, colorSpace).At(0, 0).RGBA()
+		if gray := uint8(r >> 8); gray < expected-2 || gray > expected+2 {
+			t.Errorf("%s: expected the gray level %d halfway, got %d", colorSpace, expected, gray)
+		}
+	}
+
+	// The colors are kept when only one of the images is shown
+	orange := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < 4; i++ {
+		orange.Set(i%2, i/2, color.RGBA{230, 120, 20, 255})
+	}
+	for _, colorSpace := range []string{LinearLight, Oklab} {
+		if c := color.RGBAModel.Convert(Crossfade(black, orange, 1, colorSpace).At(0, 0)); c != (color.RGBA{230, 120, 20, 255}) {
+			t.Errorf("%s: expected the color to survive the conversions, got %v", colorSpace, c)
+		}
+	}
+
+	// Overlay transitions may have a color space, and crossfade in linear light by default
+	data := []byte("stw: 1.0\n@06:00-08:00: night .. day | overlay oklab\n@08:00: day\n@20:00-22:00: day .. night\n@22:00: night\n")
+	stw, err := DataToSimple("oklab.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stw.Transitions[0].ColorSpace != Oklab || stw.Transitions[1].ColorSpace != "" {
+		t.Errorf("unexpected color spaces: %q and %q", stw.Transitions[0].ColorSpace, stw.Transitions[1].ColorSpace)
+	}
+	if s := stw.String(); !strings.Contains(s, "night .. day | overlay oklab") {
+		t.Errorf("expected the color space to be written, got:\n%s", s)
+	}
+	img, err := stw.Transitions[1].Render(black, white, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r>>8 < 186 || r>>8 > 190 {
+		t.Errorf("expected the overlay transition to crossfade in linear light, got %d", r>>8)
+	}
+	if _, err := DataToSimple("wipe.stw", []byte("stw: 1.0\n@06:00-08:00: night .. day | wipe-left oklab\n")); err == nil {
+		t.Error("expected an error for a color space on a transition that is not an overlay")
+	}
+}
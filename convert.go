@@ -3,108 +3,72 @@ package timed
 import (
 	"fmt"
 	"strings"
-	"time"
 )
 
-const simpleTimedWallpaperFormatVersion = "1.0"
+const (
+	simpleTimedWallpaperFormatVersion = "1.0"
 
-// GnomeToSimple converts a Gnome Timed Wallpaper to a Simple Timed Wallpaper
-func GnomeToSimple(gtw *FatWallpaper) (*FatWallpaper, error) {
-	// TODO: Convert from struct to struct, without excercising the serializer and the parser
-
-	// Convert the given struct to the string contents of a Wallpaper file
-	s, err := GnomeToSimpleString(gtw)
-	if err != nil {
-		return nil, err
-	}
-	return DataToSimple(gtw.Path, []byte(s))
-}
-
-// GnomeToSimpleString converts a Gnome Timed Wallpaper to a string
-// representing a Simple Timed Wallpaper. The Path field in the given
-// struct is not included in the output string.
-func GnomeToSimpleString(gtw *FatWallpaper) (string, error) {
-
-	//filename := gtw.Path
-	name := gtw.Name
-
-	var sb strings.Builder
+	// simpleTimedWallpaperSecondsVersion is the first version of the format
+	// that can have seconds in the timestamps
+	simpleTimedWallpaperSecondsVersion = "1.1"
 
-	// Output the version of the format
-	sb.WriteString("stw: " + simpleTimedWallpaperFormatVersion + "\n")
-
-	// Output the name of the timed wallpaper
-	sb.WriteString("name: " + name + "\n")
-
-	// Get the start time for the wallpaper collection (which is offset by X
-	// seconds per static wallpaper)
-	startTime := gtw.StartTime()
-
-	totalElements := len(gtw.Config.Statics) + len(gtw.Config.Transitions)
+	// simpleTimedWallpaperExtendedVersion is the first version of the format
+	// that can have event times that are relative to solar events
+	simpleTimedWallpaperExtendedVersion = "1.2"
+)
 
-	// Keep track of the total time. It is increased every time a new element duration is encountered.
-	eventTime := startTime
+// GnomeToSimple converts a Gnome Timed Wallpaper to a Simple Timed Wallpaper.
+// The event times are kept exactly as they are in the GNOME timed wallpaper.
+func GnomeToSimple(gtw *FatWallpaper) (*FatWallpaper, error) {
+	stw := NewSimple(simpleTimedWallpaperFormatVersion, gtw.Name, "")
+	stw.Path = gtw.Path
+	stw.LoopWait = gtw.LoopWait
+	stw.FrameFormat = gtw.FrameFormat
+	stw.FrameCache = gtw.FrameCache
+	stw.Dither = gtw.Dither
+	stw.Location = gtw.Location
+	stw.Names = copyStringMap(gtw.Names)
+	stw.Metadata = copyStringMap(gtw.Metadata)
+
+	// Keep track of the time since midnight, starting at the start time.
+	// It is increased every time a new element duration is encountered.
+	eventTime := sinceMidnight(gtw.StartTime())
 
-	// First, only gather all the image filenames
 	var filenames []string
+	totalElements := len(gtw.Config.Statics) + len(gtw.Config.Transitions)
 	for i := 0; i < totalElements; i++ {
 		// Get an element, by index. This is an interface{} and is expected to be a GStatic or a GTransition
 		eInterface, err := gtw.Config.Get(i)
 		if err != nil {
-			return "", fmt.Errorf("element is not a <static> or <transition> tag: error: %s", err)
+			return nil, fmt.Errorf("element is not a <static> or <transition> tag: error: %s", err)
 		}
 		if s, ok := eInterface.(GStatic); ok {
+			stw.Statics = append(stw.Statics, &Static{At: clockTime(eventTime), Filename: s.Filename})
 			filenames = append(filenames, s.Filename)
+			eventTime += s.Duration()
 		} else if t, ok := eInterface.(GTransition); ok {
-			filenames = append(filenames, t.FromFilename)
-			filenames = append(filenames, t.ToFilename)
-		}
-	}
-
-	// Then find the common prefix and suffix of the image filenames
-	commonPrefix := CommonPrefix(filenames)
-	commonSuffix := CommonSuffix(filenames)
-
-	// Output the format string
-	sb.WriteString("format: " + commonPrefix + "%s" + commonSuffix + "\n")
-
-	// Then output the timing information, for static images and for transitions
-	for i := 0; i < totalElements; i++ {
-		// The duration of the event is specified in the XML file, but not when it should start
-
-		// Get an element, by index. This is an interface{} and is expected to be a GStatic or a GTransition
-		eInterface, err := gtw.Config.Get(i)
-		if err != nil {
-			return "", fmt.Errorf("element is not a <static> or <transition> tag: error: %s", err)
-		}
-		if s, ok := eInterface.(GStatic); ok {
-			window := s.Duration()
-			if window < 1*time.Minute {
-				sb.WriteString(fmt.Sprintf("# warning: static image duration is less than a minute: %s\n", window))
-			}
-
-			sb.WriteString(fmt.Sprintf("@%s: %s\n", cFmt(eventTime), Meat(s.Filename, commonPrefix, commonSuffix)))
-
-			// Increase the variable that keeps track of the time
-			eventTime = eventTime.Add(window)
-
-		} else if t, ok := eInterface.(GTransition); ok {
-			window := t.Duration()
-			from := eventTime
-			upTo := eventTime.Add(window)
-
-			if t.Type == "overlay" || t.Type == "" {
-				sb.WriteString(fmt.Sprintf("@%s-%s: %s .. %s\n", cFmt(from), cFmt(upTo), Meat(t.FromFilename, commonPrefix, commonSuffix), Meat(t.ToFilename, commonPrefix, commonSuffix)))
-			} else {
-				sb.WriteString(fmt.Sprintf("@%s-%s: %s .. %s | %s\n", cFmt(from), cFmt(upTo), Meat(t.FromFilename, commonPrefix, commonSuffix), Meat(t.ToFilename, commonPrefix, commonSuffix), t.Type))
+			transitionType := t.Type
+			if transitionType == "" {
+				transitionType = "overlay"
 			}
-
-			// Increase the variable that keeps track of the time
-			eventTime = eventTime.Add(window)
+			stw.Transitions = append(stw.Transitions, &Transition{From: clockTime(eventTime), UpTo: clockTime(eventTime + t.Duration()), FromFilename: t.FromFilename, ToFilename: t.ToFilename, Type: transitionType})
+			filenames = append(filenames, t.FromFilename, t.ToFilename)
+			eventTime += t.Duration()
 		}
 	}
+	stw.Format = commonFormat(filenames)
+	return stw, nil
+}
 
-	return strings.TrimSpace(sb.String()), nil
+// GnomeToSimpleString converts a Gnome Timed Wallpaper to a string
+// representing a Simple Timed Wallpaper. The Path field in the given
+// struct is not included in the output string.
+func GnomeToSimpleString(gtw *FatWallpaper) (string, error) {
+	stw, err := GnomeToSimple(gtw)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stw.String()), nil
 }
 
 // GnomeFileToSimpleString reads and parses an XML file, then returns a string
@@ -1,19 +1,121 @@
 package timed
 
 import (
-	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConvert(t *testing.T) {
 	gtw, err := ParseXML("testdata/generated.xml")
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
-	fmt.Println(gtw.Config.StartTime.Year)
+	gtw.Dither = DitherOrdered
 	stw, err := GnomeToSimple(gtw)
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+	if stw.GNOME || len(stw.Statics) != 2 || len(stw.Transitions) != 0 {
+		t.Fatalf("unexpected conversion result:\n%s", stw)
+	}
+	if stw.Dither != DitherOrdered {
+		t.Errorf("expected the dithering method to be kept, got %q", stw.Dither)
+	}
+	equivalent, err := Equivalent(gtw, stw, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equivalent {
+		t.Errorf("the converted timed wallpaper is not equivalent to the GNOME timed wallpaper:\n%s", stw)
+	}
+}
+
+func TestConvertString(t *testing.T) {
+	for filename, format := range map[string]string{
+		"testdata/example2.xml":  "format: /path/to/wallpaper_number_%s.jpg",
+		"testdata/generated.xml": "format: /usr/share/wallpapers/%s/contents/images/1440x900.jpg",
+	} {
+		gtw, err := ParseXML(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gtw.Location = time.UTC
+		s, err := GnomeToSimpleString(gtw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stw, err := GnomeToSimple(gtw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != strings.TrimSpace(stw.String()) {
+			t.Errorf("%s: expected the same result from GnomeToSimpleString and GnomeToSimple, got:\n%s\nand:\n%s", filename, s, stw)
+		}
+		if !strings.Contains(s, "\n"+format+"\n") || !strings.Contains(s, "\ntz: UTC\n") {
+			t.Errorf("%s: expected %q and the time zone, got:\n%s", filename, format, s)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	xmlFilenames, err := filepath.Glob("testdata/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stwFilenames, err := filepath.Glob("testdata/*.stw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wallpapers []*FatWallpaper
+	for _, filename := range xmlFilenames {
+		gtw, err := ParseXML(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallpapers = append(wallpapers, gtw)
+	}
+	for _, filename := range stwFilenames {
+		stw, err := ParseSTW(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallpapers = append(wallpapers, stw)
+	}
+	for _, fw := range wallpapers {
+		stw, err := fw.toSimple()
+		if err != nil {
+			t.Fatal(err)
+		}
+		written := stw.String()
+		parsed, err := DataToSimple(fw.Path, []byte(written))
+		if err != nil {
+			t.Fatalf("%s: %s", fw.Path, err)
+		}
+		equivalent, err := Equivalent(fw, parsed, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equivalent {
+			t.Errorf("%s changed when converted to:\n%s", fw.Path, written)
+		}
+	}
+}
+
+func TestNotEquivalent(t *testing.T) {
+	stw, err := ParseSTW("testdata/adwaita-timed2.stw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shifted, err := stw.Shift(10 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if equivalent, err := Equivalent(stw, shifted, 5*time.Minute); err != nil || equivalent {
+		t.Errorf("expected a timed wallpaper shifted by 10m to differ with a 5m tolerance, got %v, %v", equivalent, err)
+	}
+	if equivalent, err := Equivalent(stw, shifted, 10*time.Minute); err != nil || !equivalent {
+		t.Errorf("expected a timed wallpaper shifted by 10m to be equivalent with a 10m tolerance, got %v, %v", equivalent, err)
 	}
-	fmt.Println(stw)
 }
@@ -0,0 +1,276 @@
+package timed
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSpec is a cron expression, with the five fields minute, hour, day of
+// the month, month and day of the week. Each field is "*", a number, a
+// range like "9-17", a step like "*/15" or "9-17/2", or a comma separated
+// list of these. Months and days of the week may also be given by name,
+// like "jan" or "mon", and a day of the week may be followed by "#" and a
+// number, like "mon#1" for the first Monday of the month.
+type CronSpec struct {
+	Expression  string
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	weekdays    uint64
+	nthWeekdays map[time.Weekday][]int // from "#", like "mon#1"
+	anyDay      bool                   // the day of the month is "*"
+	anyWeekday  bool                   // the day of the week is "*"
+	windows     []*Include             // from includes, where only the times within all of the windows are used
+}
+
+var (
+	cronMonthNames   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronWeekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronValue parses a number or a name in a cron field
+func cronValue(s string, names []string, offset int) (int, error) {
+	for i, name := range names {
+		if strings.ToLower(s) == name {
+			return i + offset, nil
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+// parseCronField parses a field of a cron expression into a bit set, where
+// min and max are the smallest and largest allowed values
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i != -1 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in cron field: %s", item)
+			}
+			item = item[:i]
+		}
+		first, last := min, max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			fields := strings.SplitN(item, "-", 2)
+			var err1, err2 error
+			first, err1 = cronValue(fields[0], names, min)
+			last, err2 = cronValue(fields[1], names, min)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range in cron field: %s", item)
+			}
+		default:
+			var err error
+			first, err = cronValue(item, names, min)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in cron field: %s", item)
+			}
+			if step == 1 {
+				last = first
+			}
+		}
+		if first < min || last > max || first > last {
+			return 0, fmt.Errorf("cron field out of range: %s", item)
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// ParseCron parses a cron expression, like "*/15 9-17 * * mon-fri" for every
+// 15 minutes during work hours, or "0 8 * * mon#1" for 08:00 on the first
+// Monday of every month
+func ParseCron(expression string) (*CronSpec, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("a cron expression must have 5 fields: %s", expression)
+	}
+	spec := &CronSpec{Expression: strings.Join(fields, " "), anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	var err error
+	if spec.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if spec.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if spec.daysOfMonth, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if spec.months, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, err
+	}
+	// The days of the week may be followed by "#" and which one in the month it is
+	var weekdays []string
+	for _, item := range strings.Split(fields[4], ",") {
+		i := strings.Index(item, "#")
+		if i == -1 {
+			weekdays = append(weekdays, item)
+			continue
+		}
+		wd, err := cronValue(item[:i], cronWeekdayNames, 0)
+		n, err2 := strconv.Atoi(item[i+1:])
+		if err != nil || err2 != nil || wd < 0 || wd > 7 || n < 1 || n > 5 {
+			return nil, fmt.Errorf("invalid day of the week in cron field: %s", item)
+		}
+		if spec.nthWeekdays == nil {
+			spec.nthWeekdays = make(map[time.Weekday][]int)
+		}
+		spec.nthWeekdays[time.Weekday(wd%7)] = append(spec.nthWeekdays[time.Weekday(wd%7)], n)
+	}
+	if len(weekdays) > 0 {
+		if spec.weekdays, err = parseCronField(strings.Join(weekdays, ","), 0, 7, cronWeekdayNames); err != nil {
+			return nil, err
+		}
+		// Both 0 and 7 are Sunday
+		if spec.weekdays&(1<<7) != 0 {
+			spec.weekdays |= 1
+		}
+	}
+	return spec, nil
+}
+
+// String returns the cron expression
+func (spec *CronSpec) String() string {
+	return spec.Expression
+}
+
+// matchesDay checks if the cron expression applies to the date of the given time
+func (spec *CronSpec) matchesDay(date time.Time) bool {
+	if spec.months&(1<<uint(date.Month())) == 0 {
+		return false
+	}
+	dayOfMonth := spec.daysOfMonth&(1<<uint(date.Day())) != 0
+	weekday := spec.weekdays&(1<<uint(date.Weekday())) != 0
+	for _, n := range spec.nthWeekdays[date.Weekday()] {
+		weekday = weekday || (date.Day()-1)/7+1 == n
+	}
+	// As in cron, if both the day of the month and the day of the week are
+	// restricted, either of them may match
+	switch {
+	case spec.anyDay && spec.anyWeekday:
+		return true
+	case spec.anyDay:
+		return weekday
+	case spec.anyWeekday:
+		return dayOfMonth
+	}
+	return dayOfMonth || weekday
+}
+
+// Matches checks if the cron expression applies to the given time, to the minute
+func (spec *CronSpec) Matches(t time.Time) bool {
+	return spec.matchesDay(t) && spec.hours&(1<<uint(t.Hour())) != 0 && spec.minutes&(1<<uint(t.Minute())) != 0
+}
+
+// Times returns the times at the date of the given time where the cron
+// expression applies, in the time zone of the given time. Times that do not
+// exist on that date, because of daylight saving time, are skipped.
+func (spec *CronSpec) Times(date time.Time) []time.Time {
+	if !spec.matchesDay(date) {
+		return nil
+	}
+	var times []time.Time
+	for hour := 0; hour < 24; hour++ {
+		if spec.hours&(1<<uint(hour)) == 0 {
+			continue
+		}
+		for minute := 0; minute < 60; minute++ {
+			if spec.minutes&(1<<uint(minute)) == 0 {
+				continue
+			}
+			t := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location())
+			if t.Hour() == hour && t.Minute() == minute && spec.inWindows(t) {
+				times = append(times, t)
+			}
+		}
+	}
+	return times
+}
+
+// inWindows checks if the given time of day is within all of the windows
+// of the includes that the cron expression is from
+func (spec *CronSpec) inWindows(t time.Time) bool {
+	for _, inc := range spec.windows {
+		if !inc.within(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// usesCron checks if any of the static images are shown according to cron expressions
+func (fw *FatWallpaper) usesCron() bool {
+	for _, s := range fw.Statics {
+		if s.Cron != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// expandCron replaces the static images that are shown according to cron
+// expressions with static images at the times where the cron expressions
+// apply, at the given date. Other static images that start at the same
+// time as a cron image are removed, since the cron image replaces them.
+func (fw *FatWallpaper) expandCron(date time.Time) {
+	if !fw.usesCron() {
+		return
+	}
+	date = date.In(fw.location())
+	cronTimes := make(map[time.Duration][]time.Time)
+	for _, s := range fw.Statics {
+		if s.Cron != nil {
+			for _, t := range s.Cron.Times(date) {
+				cronTimes[sinceMidnight(t)] = append(cronTimes[sinceMidnight(t)], t)
+			}
+		}
+	}
+	var statics []*Static
+	for _, s := range fw.Statics {
+		if s.Cron == nil {
+			if _, replaced := cronTimes[sinceMidnight(s.At)]; !replaced {
+				statics = append(statics, s)
+			}
+			continue
+		}
+		for _, t := range s.Cron.Times(date) {
+			expanded := *s
+			expanded.At = clockTime(sinceMidnight(t))
+			expanded.Cron = nil
+			statics = append(statics, &expanded)
+		}
+	}
+	fw.Statics = statics
+}
+
+// cronReferenceYear is a leap year, where every day is used when comparing
+// timed wallpapers with cron expressions
+const cronReferenceYear = 2024
+
+// cronDays returns copies of the two Simple Timed Wallpapers, where the
+// cron expressions have been expanded, for every day of the cron reference
+// year that gives a different pair of schedules
+func cronDays(a, b *FatWallpaper) [][2]*FatWallpaper {
+	var pairs [][2]*FatWallpaper
+	seen := make(map[string]bool)
+	for day := time.Date(cronReferenceYear, 1, 1, 12, 0, 0, 0, a.location()); day.Year() == cronReferenceYear; day = day.AddDate(0, 0, 1) {
+		expandedA, expandedB := a.Copy(), b.Copy()
+		expandedA.expandCron(day)
+		expandedB.expandCron(day)
+		key := expandedA.String() + "\x00" + expandedB.String()
+		if !seen[key] {
+			seen[key] = true
+			pairs = append(pairs, [2]*FatWallpaper{expandedA, expandedB})
+		}
+	}
+	return pairs
+}
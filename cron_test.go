@@ -0,0 +1,101 @@
+package timed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	spec, err := ParseCron("0 8 * * mon#1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var firstMondays []int
+	for day := 1; day <= 31; day++ {
+		if times := spec.Times(time.Date(2026, 10, day, 0, 0, 0, 0, time.UTC)); len(times) > 0 {
+			firstMondays = append(firstMondays, day)
+			if cFmt(times[0]) != "08:00" {
+				t.Errorf("expected 08:00, got %s", cFmt(times[0]))
+			}
+		}
+	}
+	if len(firstMondays) != 1 || firstMondays[0] != 5 {
+		t.Errorf("expected the first Monday of October 2026 to be the 5th, got %v", firstMondays)
+	}
+	for _, invalid := range []string{"* * * *", "60 * * * *", "* 9-25 * * *", "*/0 * * * *", "* * * * mon#6"} {
+		if _, err := ParseCron(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+
+	stw, err := DataToSimple("cron.stw", []byte("stw: 1.0\ntz: UTC\n@07:00: morning\n@cron */15 9-17 * * mon-fri: slide\n@18:00: evening\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.Validate(); err != nil {
+		t.Error(err)
+	}
+	friday := time.Date(2026, 10, 16, 9, 20, 0, 0, time.UTC)
+	e, err := stw.NextEvent(friday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := stw.UntilNext(friday); d != 10*time.Minute {
+		t.Errorf("expected 10m until the next slide, got %s (%v)", d, e)
+	}
+	saturday := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	e, err = stw.NextEvent(saturday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := e.(*Static); !ok || s.Filename != "evening" {
+		t.Errorf("expected no slides on a Saturday, got %v", e)
+	}
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	occurrences, err := stw.Occurrences(day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The evening image from the day before, the morning image, 36 slides and the evening image
+	if len(occurrences) != 39 {
+		t.Errorf("expected 39 events, got %d", len(occurrences))
+	}
+	reparsed, err := DataToSimple("cron.stw", []byte(stw.String()))
+	if err != nil || len(reparsed.Statics) != 3 || !reparsed.usesCron() {
+		t.Errorf("expected the cron expression to be kept when writing and parsing, got:\n%s", stw)
+	}
+}
+
+func TestCronEquivalentAndCollisions(t *testing.T) {
+	parse := func(data string) *FatWallpaper {
+		t.Helper()
+		stw, err := DataToSimple("cron.stw", []byte("stw: 1.2\ntz: UTC\n"+data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stw
+	}
+	// The days of the week of cron expressions are compared
+	weekdays := parse("@00:00: night\n@cron 0 12 * * mon-fri: lunch\n@13:00: day\n")
+	everyDay := parse("@00:00: night\n@12:00: lunch\n@13:00: day\n")
+	never := parse("@00:00: night\n@13:00: day\n")
+	if ok, err := Equivalent(weekdays, weekdays.Copy(), 0); !ok || err != nil {
+		t.Errorf("expected the timed wallpaper to be equivalent to itself: %v", err)
+	}
+	if ok, err := Equivalent(weekdays, everyDay, 0); ok || err != nil {
+		t.Errorf("expected the weekend to differ: %v", err)
+	}
+	if ok, err := Equivalent(weekdays, never, 0); ok || err != nil {
+		t.Errorf("expected the weekdays to differ: %v", err)
+	}
+	// Cron images replace static images at the same time, in any order
+	for _, data := range []string{"@00:00: night\n@12:00: plain\n@cron 0 12 * * *: cron\n", "@00:00: night\n@cron 0 12 * * *: cron\n@12:00: plain\n"} {
+		shown, _, _, err := parse(data).Shown(time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shown != "cron" {
+			t.Errorf("expected the cron image to replace the other image, got %s", shown)
+		}
+	}
+}
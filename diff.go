@@ -0,0 +1,186 @@
+package timed
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChangeKind is the kind of change to an event, between two timed wallpapers
+type ChangeKind int
+
+const (
+	// EventAdded is an event that is only in the second timed wallpaper
+	EventAdded ChangeKind = iota
+	// EventRemoved is an event that is only in the first timed wallpaper
+	EventRemoved
+	// EventRetimed is an event with the same images, but at another time
+	EventRetimed
+	// EventChanged is an event at the same time, but with other images or another transition type
+	EventChanged
+)
+
+// String returns the change kind as a word
+func (k ChangeKind) String() string {
+	switch k {
+	case EventAdded:
+		return "added"
+	case EventRemoved:
+		return "removed"
+	case EventRetimed:
+		return "retimed"
+	case EventChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Difference is a change to one event, between two timed wallpapers.
+// Old and New are either *Static or *Transition. Old is nil for added events
+// and New is nil for removed events.
+type Difference struct {
+	Kind ChangeKind
+	Old  interface{}
+	New  interface{}
+}
+
+// String returns a description of the difference, with the events in the
+// verbose Simple Timed Wallpaper syntax
+func (d *Difference) String() string {
+	switch d.Kind {
+	case EventAdded:
+		return fmt.Sprintf("added: %s", eventString(d.New))
+	case EventRemoved:
+		return fmt.Sprintf("removed: %s", eventString(d.Old))
+	}
+	return fmt.Sprintf("%s: %s => %s", d.Kind, eventString(d.Old), eventString(d.New))
+}
+
+// eventString returns a *Static or *Transition as a string, without using a format string
+func eventString(e interface{}) string {
+	switch v := e.(type) {
+	case *Static:
+		return v.String("")
+	case *Transition:
+		return v.String("")
+	}
+	return ""
+}
+
+// eventStart returns when a *Static or *Transition starts
+func eventStart(e interface{}) time.Time {
+	switch v := e.(type) {
+	case *Static:
+		return v.At
+	case *Transition:
+		return v.From
+	}
+	return time.Time{}
+}
+
+// timeKey returns a string that is the same for events of the same kind at the same time
+func timeKey(e interface{}) string {
+	switch v := e.(type) {
+	case *Static:
+		return "static " + v.timestamp()
+	case *Transition:
+		return "transition " + v.timestamps()
+	}
+	return ""
+}
+
+// imageKey returns a string that is the same for events of the same kind with the same images
+func imageKey(e interface{}) string {
+	switch v := e.(type) {
+	case *Static:
+		return "static " + v.Filename
+	case *Transition:
+		return "transition " + v.FromFilename + "\n" + v.ToFilename + "\n" + v.options()
+	}
+	return ""
+}
+
+// events returns all static and transition events of a Simple Timed
+// Wallpaper, sorted by when they start
+func (fw *FatWallpaper) events() []interface{} {
+	var events []interface{}
+	for _, s := range fw.Statics {
+		events = append(events, s)
+	}
+	for _, t := range fw.Transitions {
+		events = append(events, t)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return sinceMidnight(eventStart(events[i])) < sinceMidnight(eventStart(events[j]))
+	})
+	return events
+}
+
+// Diff compares two timed wallpapers, event by event, and returns the
+// differences. The timed wallpapers may be in different formats, since GNOME
+// timed wallpapers are converted to Simple Timed Wallpapers first, and full
+// filenames are compared, regardless of how the format strings are written.
+func Diff(a, b *FatWallpaper) ([]*Difference, error) {
+	stwA, err := a.toSimple()
+	if err != nil {
+		return nil, err
+	}
+	stwB, err := b.toSimple()
+	if err != nil {
+		return nil, err
+	}
+	oldEvents := stwA.events()
+	newEvents := stwB.events()
+
+	var diffs []*Difference
+
+	// match pairs up old and new events where the given key function gives
+	// the same key, and removes them from the lists of events
+	match := func(key func(interface{}) string, kind ChangeKind, record bool) {
+		var unmatched []interface{}
+		for _, o := range oldEvents {
+			found := -1
+			for i, n := range newEvents {
+				if key(o) == key(n) {
+					found = i
+					break
+				}
+			}
+			if found == -1 {
+				unmatched = append(unmatched, o)
+				continue
+			}
+			if record {
+				diffs = append(diffs, &Difference{kind, o, newEvents[found]})
+			}
+			newEvents = append(newEvents[:found], newEvents[found+1:]...)
+		}
+		oldEvents = unmatched
+	}
+
+	// Unchanged events
+	match(func(e interface{}) string { return timeKey(e) + "\n" + imageKey(e) }, EventChanged, false)
+	// Same images, but another time
+	match(imageKey, EventRetimed, true)
+	// Same time, but other images
+	match(timeKey, EventChanged, true)
+
+	for _, o := range oldEvents {
+		diffs = append(diffs, &Difference{EventRemoved, o, nil})
+	}
+	for _, n := range newEvents {
+		diffs = append(diffs, &Difference{EventAdded, nil, n})
+	}
+
+	// Sort the differences by when the events start
+	start := func(d *Difference) time.Duration {
+		if d.Old != nil {
+			return sinceMidnight(eventStart(d.Old))
+		}
+		return sinceMidnight(eventStart(d.New))
+	}
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return start(diffs[i]) < start(diffs[j])
+	})
+	return diffs, nil
+}
@@ -0,0 +1,48 @@
+package timed
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDiffFormats(t *testing.T) {
+	gtw, err := ParseXML("testdata/adwaita-timed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stw, err := ParseSTW("testdata/adwaita-timed2.stw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := Diff(gtw, stw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no differences, got %v", diffs)
+	}
+}
+
+func ExampleDiff() {
+	stw, err := ParseSTW("testdata/adwaita-timed2.stw")
+	if err != nil {
+		panic(err)
+	}
+	changed := stw.Copy()
+	changed.Statics[0].At = changed.Statics[0].At.Add(30 * time.Minute)
+	changed.Statics[1].Filename = "/usr/share/backgrounds/gnome/adwaita-noon.jpg"
+	changed.Transitions = changed.Transitions[1:]
+	diffs, err := Diff(stw, changed)
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+
+	// Output:
+	// retimed: @07:00: /usr/share/backgrounds/gnome/adwaita-morning.jpg => @07:30: /usr/share/backgrounds/gnome/adwaita-morning.jpg
+	// removed: @08:00-13:00: /usr/share/backgrounds/gnome/adwaita-morning.jpg .. /usr/share/backgrounds/gnome/adwaita-day.jpg
+	// changed: @13:00: /usr/share/backgrounds/gnome/adwaita-day.jpg => @13:00: /usr/share/backgrounds/gnome/adwaita-noon.jpg
+}
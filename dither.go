@@ -0,0 +1,76 @@
+package timed
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/anthonynsimon/bild/parallel"
+)
+
+// The dithering methods that can be used when 16-bit frames are written as
+// 8-bit images, to avoid visible bands in slow crossfades between gradients
+const (
+	DitherNone    = "none"    // the default, where the values are rounded
+	DitherOrdered = "ordered" // an 8x8 Bayer matrix
+	DitherNoise   = "noise"   // interleaved gradient noise, which looks like blue noise
+)
+
+// bayer8 is the 8x8 Bayer matrix for ordered dithering
+var bayer8 = [8][8]float64{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// checkDither returns an error if the given dithering method is unknown
+func checkDither(method string) error {
+	switch method {
+	case "", DitherNone, DitherOrdered, DitherNoise:
+		return nil
+	}
+	return fmt.Errorf("unknown dithering method: %s", method)
+}
+
+// ditherThreshold returns the threshold from 0 up to 1 for the given
+// pixel, where 0.5 is used when there is no dithering
+func ditherThreshold(method string, x, y int) float64 {
+	switch method {
+	case DitherOrdered:
+		return (bayer8[y%8][x%8] + 0.5) / 64
+	case DitherNoise:
+		v := 0.06711056*float64(x) + 0.00583715*float64(y)
+		v = 52.9829189 * (v - math.Floor(v))
+		return v - math.Floor(v)
+	}
+	return 0.5
+}
+
+// Dither converts a 16-bit image to an 8-bit image with the given
+// dithering method. Other images are returned as they are.
+func Dither(img image.Image, method string) image.Image {
+	src, ok := img.(*image.RGBA64)
+	if !ok {
+		return img
+	}
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	parallel.Line(b.Dy(), func(start, end int) {
+		for y := b.Min.Y + start; y < b.Min.Y+end; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				threshold := ditherThreshold(method, x, y)
+				si, di := src.PixOffset(x, y), dst.PixOffset(x, y)
+				for c := 0; c < 4; c++ {
+					v := float64(uint16(src.Pix[si+2*c])<<8|uint16(src.Pix[si+2*c+1])) / 257
+					dst.Pix[di+c] = uint8(math.Min(255, math.Floor(v+threshold)))
+				}
+			}
+		}
+	})
+	return dst
+}
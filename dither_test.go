@@ -0,0 +1,44 @@
+package timed
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestDither(t *testing.T) {
+	// A gray level between two 8-bit levels
+	img := image.NewRGBA64(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA64{100*257 + 64, 100*257 + 64, 100*257 + 64, 0xffff})
+		}
+	}
+	for method, expected := range map[string]float64{DitherNone: 100, DitherOrdered: 100.25, DitherNoise: 100.25} {
+		dithered := Dither(img, method).(*image.RGBA)
+		sum := 0
+		for i := 0; i < len(dithered.Pix); i += 4 {
+			if v := dithered.Pix[i]; v != 100 && v != 101 {
+				t.Fatalf("%s: expected the gray levels 100 and 101, got %d", method, v)
+			}
+			sum += int(dithered.Pix[i])
+		}
+		if average := float64(sum) / (64 * 64); average < expected-0.03 || average > expected+0.03 {
+			t.Errorf("%s: expected the average gray level %v, got %v", method, expected, average)
+		}
+	}
+
+	// The dithering method is a field in Simple Timed Wallpaper files
+	data := []byte("stw: 1.0\ndither: ordered\n@00:00-12:00: night .. day\n@12:00: day\n")
+	stw, err := DataToSimple("dither.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stw.Dither != DitherOrdered || !strings.Contains(stw.String(), "dither: ordered\n") {
+		t.Errorf("expected the dither field to be read and written, got:\n%s", stw)
+	}
+	if _, err := DataToSimple("dither.stw", []byte("stw: 1.0\ndither: floyd\n@00:00: day\n")); err == nil {
+		t.Error("expected an error for an unknown dithering method")
+	}
+}
@@ -0,0 +1,151 @@
+package timed
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Easing is an easing curve, which changes how the progress of a
+// transition is spread out over the time of the transition. The built-in
+// curves are "linear", "ease-in", "ease-out" and "smoothstep". The curve
+// may also be "cubic-bezier" with four parameters, as in CSS, or "curve"
+// with a table of at least two progress values at evenly spaced times.
+type Easing struct {
+	Name   string
+	Params []float64
+}
+
+// easingNames are the easing curves that have no parameters
+var easingNames = []string{"linear", "ease-in", "ease-out", "smoothstep"}
+
+// isEasing checks if the given transition option is an easing curve
+func isEasing(option string) bool {
+	for _, name := range easingNames {
+		if option == name {
+			return true
+		}
+	}
+	return strings.HasPrefix(option, "cubic-bezier(") || strings.HasPrefix(option, "curve(")
+}
+
+// parseParams parses comma separated numbers within parentheses, after the given name
+func parseParams(s, name string) ([]float64, error) {
+	if !strings.HasPrefix(s, name+"(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid %s: %s", name, s)
+	}
+	var params []float64
+	for _, field := range strings.Split(s[len(name)+1:len(s)-1], ",") {
+		param, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number in %s: %s", name, field)
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// ParseEasing parses an easing curve, like "smoothstep",
+// "cubic-bezier(0.4,0,0.2,1)" or "curve(0,0.1,0.3,1)"
+func ParseEasing(s string) (*Easing, error) {
+	for _, name := range easingNames {
+		if s == name {
+			return &Easing{Name: name}, nil
+		}
+	}
+	switch {
+	case strings.HasPrefix(s, "cubic-bezier("):
+		params, err := parseParams(s, "cubic-bezier")
+		if err != nil {
+			return nil, err
+		}
+		if len(params) != 4 || params[0] < 0 || params[0] > 1 || params[2] < 0 || params[2] > 1 {
+			return nil, fmt.Errorf("cubic-bezier needs four numbers, where the first and the third are from 0 to 1: %s", s)
+		}
+		return &Easing{Name: "cubic-bezier", Params: params}, nil
+	case strings.HasPrefix(s, "curve("):
+		params, err := parseParams(s, "curve")
+		if err != nil {
+			return nil, err
+		}
+		if len(params) < 2 {
+			return nil, fmt.Errorf("curve needs at least two numbers: %s", s)
+		}
+		for _, param := range params {
+			if param < 0 || param > 1 {
+				return nil, fmt.Errorf("the numbers of a curve must be from 0 to 1: %s", s)
+			}
+		}
+		return &Easing{Name: "curve", Params: params}, nil
+	}
+	return nil, fmt.Errorf("unknown easing curve: %s", s)
+}
+
+// String returns the easing curve, as written in Simple Timed Wallpaper files
+func (e *Easing) String() string {
+	if len(e.Params) == 0 {
+		return e.Name
+	}
+	var params []string
+	for _, param := range e.Params {
+		params = append(params, strconv.FormatFloat(param, 'f', -1, 64))
+	}
+	return e.Name + "(" + strings.Join(params, ",") + ")"
+}
+
+// bezier returns a coordinate of a cubic Bézier curve from 0 to 1, with
+// the two given control point coordinates
+func bezier(p1, p2, t float64) float64 {
+	return 3*(1-t)*(1-t)*t*p1 + 3*(1-t)*t*t*p2 + t*t*t
+}
+
+// Apply returns the eased progress, for the given linear progress from 0 to 1
+func (e *Easing) Apply(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	switch e.Name {
+	case "ease-in":
+		return x * x
+	case "ease-out":
+		return 1 - (1-x)*(1-x)
+	case "smoothstep":
+		return x * x * (3 - 2*x)
+	case "cubic-bezier":
+		// Find the point on the curve where the time is x, by bisection,
+		// since the time always increases along the curve
+		low, high := 0.0, 1.0
+		for i := 0; i < 32; i++ {
+			mid := (low + high) / 2
+			if bezier(e.Params[0], e.Params[2], mid) < x {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+		return bezier(e.Params[1], e.Params[3], (low+high)/2)
+	case "curve":
+		// Interpolate linearly between the values in the table
+		pos := x * float64(len(e.Params)-1)
+		i := int(math.Floor(pos))
+		if i >= len(e.Params)-1 {
+			return e.Params[len(e.Params)-1]
+		}
+		return e.Params[i] + (e.Params[i+1]-e.Params[i])*(pos-float64(i))
+	}
+	return x
+}
+
+// usesEasing checks if any of the transitions has an easing curve
+func (fw *FatWallpaper) usesEasing() bool {
+	for _, t := range fw.Transitions {
+		if t.Easing != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package timed
+
+import (
+	"image"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEasing(t *testing.T) {
+	for s, expected := range map[string]float64{
+		"linear":                   0.25,
+		"ease-in":                  0.0625,
+		"ease-out":                 0.4375,
+		"smoothstep":               0.15625,
+		"cubic-bezier(0,0,1,1)":    0.25,
+		"cubic-bezier(0.42,0,1,1)": 0.0934,
+		"curve(0,0.8,1)":           0.4,
+	} {
+		e, err := ParseEasing(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Apply(0.25); math.Abs(got-expected) > 0.001 {
+			t.Errorf("%s at 0.25: expected %v, got %v", s, expected, got)
+		}
+		if e.Apply(0) != 0 || e.Apply(1) != 1 {
+			t.Errorf("%s: expected to go from 0 to 1", s)
+		}
+		if e.String() != s {
+			t.Errorf("expected %s, got %s", s, e)
+		}
+	}
+	for _, s := range []string{"bounce", "cubic-bezier(2,0,1,1)", "cubic-bezier(0,0)", "curve(1)", "curve(0,2,1)", "curve(0,-0.5,1)"} {
+		if _, err := ParseEasing(s); err == nil {
+			t.Errorf("expected an error for %s", s)
+		}
+	}
+
+	// Most of a long transition from day to night happens near the end
+	data := []byte("stw: 1.0\n@18:00-00:00: day .. night | overlay ease-in\n@00:00: night\n@12:00: day\n")
+	stw, err := DataToSimple("dusk.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ratio, _ := stw.Shown(clockTime(21 * time.Hour)); ratio != 0.25 {
+		t.Errorf("expected the eased ratio 0.25 halfway through the transition, got %v", ratio)
+	}
+	if s := stw.String(); !strings.Contains(s, "| overlay ease-in") || !strings.HasPrefix(s, "stw: 1.2") {
+		t.Errorf("expected the easing curve to be written, got:\n%s", s)
+	}
+	linear, err := DataToSimple("dusk.stw", []byte(strings.Replace(string(data), " ease-in", "", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if equivalent, _ := Equivalent(stw, linear, 0); equivalent {
+		t.Error("expected the eased transition to differ from the linear one")
+	}
+
+	// Easing curves that overshoot are clamped before the frames are rendered
+	overshoot, err := ParseEasing("cubic-bezier(0.5,-1,0.5,2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterTransition("strict", func(from, to image.Image, ratio float64) image.Image {
+		if ratio < 0 || ratio > 1 {
+			t.Errorf("expected a ratio from 0 to 1, got %v", ratio)
+		}
+		return from
+	})
+	defer func() {
+		transitionMut.Lock()
+		delete(transitionFuncs, "strict")
+		transitionMut.Unlock()
+	}()
+	for _, name := range []string{"strict", "slide-left"} {
+		tr := &Transition{Type: name, Easing: overshoot}
+		for x := 0.0; x <= 1; x += 0.1 {
+			if _, err := tr.Render(uniform(0), uniform(255), overshoot.Apply(x)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package timed
+
+import (
+	"math"
+	"time"
+)
+
+// ratioTolerance is how much blend ratios may differ while still being
+// considered the same
+const ratioTolerance = 0.01
+
+// frame is what is shown at a point in time: an image, possibly blended
+// with another image by the given ratio
+type frame struct {
+	from  string
+	to    string
+	ratio float64
+}
+
+// shownFrame finds what is shown at the given time, as a normalized frame,
+// where transitions that have not started or are complete are shown as the
+// image that is fully visible
+func (fw *FatWallpaper) shownFrame(at time.Duration) (frame, error) {
+	from, to, ratio, err := fw.Shown(clockTime(at))
+	if err != nil {
+		return frame{}, err
+	}
+	switch {
+	case to == "" || from == to || ratio <= ratioTolerance:
+		return frame{from: from}, nil
+	case ratio >= 1-ratioTolerance:
+		return frame{from: to}, nil
+	}
+	return frame{from, to, ratio}, nil
+}
+
+// boundaries returns the times of day where the shown image may change
+func (fw *FatWallpaper) boundaries() []time.Duration {
+	var times []time.Duration
+	for _, s := range fw.Statics {
+		times = append(times, sinceMidnight(s.At))
+	}
+	for _, t := range fw.Transitions {
+		from := sinceMidnight(t.From)
+		times = append(times, from, from+t.Duration()/2, from+t.Duration())
+	}
+	return times
+}
+
+// Equivalent checks if two timed wallpapers show the same images, blended
+// with the same ratios, throughout the day. The timed wallpapers may be in
+// different formats, the events may be listed in any order and the
+// filenames may be written with or without format strings. Timed wallpapers
+// in different time zones are not equivalent. Changes that
+// are moved in time by less than the given tolerance are accepted. Events
+// that only apply to some days are compared with the events for the same
+// days in the other timed wallpaper.
+func Equivalent(a, b *FatWallpaper, tolerance time.Duration) (bool, error) {
+	stwA, err := a.toSimple()
+	if err != nil {
+		return false, err
+	}
+	stwB, err := b.toSimple()
+	if err != nil {
+		return false, err
+	}
+	if stwA.location().String() != stwB.location().String() {
+		// The events happen at different times
+		return false, nil
+	}
+	for _, key := range append(stwA.conditions(), stwB.conditions()...) {
+		scheduleA := stwA.schedule(key)
+		scheduleB := stwB.schedule(key)
+		emptyA := len(scheduleA.Statics) == 0 && len(scheduleA.Transitions) == 0
+		emptyB := len(scheduleB.Statics) == 0 && len(scheduleB.Transitions) == 0
+		if emptyA || emptyB {
+			if emptyA != emptyB {
+				return false, nil
+			}
+			continue
+		}
+		if ok, err := equivalentSchedules(scheduleA, scheduleB, tolerance); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// equivalentSchedules checks if two Simple Timed Wallpapers, where the
+// events apply to every day, show the same images throughout the day.
+// Static images that are shown according to cron expressions are compared
+// for every day where the cron expressions give different events.
+func equivalentSchedules(stwA, stwB *FatWallpaper, tolerance time.Duration) (bool, error) {
+	if stwA.usesCron() || stwB.usesCron() {
+		for _, pair := range cronDays(stwA, stwB) {
+			if ok, err := equivalentSchedules(pair[0], pair[1], tolerance); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	// Check every minute, and right around every point in time where something changes
+	var times []time.Duration
+	for t := time.Duration(0); t < h24; t += time.Minute {
+		times = append(times, t)
+	}
+	for _, t := range append(stwA.boundaries(), stwB.boundaries()...) {
+		times = append(times, t-time.Second, t, t+time.Second)
+	}
+
+	// How far apart in time the shown frames may be compared
+	step := tolerance / 32
+	if step < time.Second {
+		step = time.Second
+	}
+
+	// matches checks if what x shows at the given time is also shown by y
+	// at about the same time. For transitions, the blend ratio shown by x must
+	// be within the range of blend ratios that y shows, between the same two
+	// images, within the tolerance.
+	matches := func(x, y *FatWallpaper, at time.Duration) (bool, error) {
+		fx, err := x.shownFrame(at)
+		if err != nil {
+			return false, err
+		}
+		// The ratio for short transitions may differ more when the step is large
+		allowed := ratioTolerance
+		if window := x.transitionWindow(at); fx.to != "" && window > 0 {
+			allowed += float64(step) / float64(window)
+		}
+		low, high := math.Inf(1), math.Inf(-1)
+		for offset := -tolerance; offset <= tolerance; offset += step {
+			fy, err := y.shownFrame(at + offset)
+			if err != nil {
+				return false, err
+			}
+			if fx.to == "" {
+				if fy == fx {
+					return true, nil
+				}
+				continue
+			}
+			// Find how far from fx.from to fx.to the frame shown by y is
+			position := math.NaN()
+			switch {
+			case fy.to == "" && fy.from == fx.from:
+				position = 0
+			case fy.to == "" && fy.from == fx.to:
+				position = 1
+			case fy.from == fx.from && fy.to == fx.to:
+				position = fy.ratio
+			case fy.from == fx.to && fy.to == fx.from:
+				position = 1 - fy.ratio
+			}
+			if !math.IsNaN(position) {
+				low = math.Min(low, position)
+				high = math.Max(high, position)
+			}
+		}
+		return low-allowed <= fx.ratio && fx.ratio <= high+allowed, nil
+	}
+
+	for _, at := range times {
+		at = mod24(at)
+		if ok, err := matches(stwA, stwB, at); !ok || err != nil {
+			return false, err
+		}
+		if ok, err := matches(stwB, stwA, at); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// transitionWindow returns how long the transition that is ongoing at the
+// given time of day lasts, or 0 if no transition is ongoing
+func (fw *FatWallpaper) transitionWindow(at time.Duration) time.Duration {
+	for _, t := range fw.Transitions {
+		if mod24(at-sinceMidnight(t.From)) < t.Duration() {
+			return t.Duration()
+		}
+	}
+	return 0
+}
@@ -3,6 +3,9 @@ package timed
 import (
 	"errors"
 	"fmt"
+	"image"
+	"io"
+	"math"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -10,250 +13,453 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/anthonynsimon/bild/blend"
-	"github.com/anthonynsimon/bild/imgio"
 	"github.com/xyproto/event"
 )
 
 var setmut = &sync.RWMutex{}
 
+// actions keeps track of the actions of the event loop that are running,
+// so that the event loop can wait for them before removing the frames.
+// The events themselves can not be waited for, since they sleep through
+// their cooldown after the action is done.
+type actions struct {
+	wg      sync.WaitGroup
+	mut     sync.Mutex
+	stopped bool
+}
+
+// run runs the given action, unless the event loop has stopped
+func (a *actions) run(action func()) {
+	a.mut.Lock()
+	if a.stopped {
+		a.mut.Unlock()
+		return
+	}
+	a.wg.Add(1)
+	a.mut.Unlock()
+	defer a.wg.Done()
+	action()
+}
+
+// stop makes sure that no more actions are run, and waits for the running ones
+func (a *actions) stop() {
+	a.mut.Lock()
+	a.stopped = true
+	a.mut.Unlock()
+	a.wg.Wait()
+}
+
 // UntilNext finds the duration until the next event starts
 func (fw *FatWallpaper) UntilNext(et time.Time) time.Duration {
-	var startTimes []time.Time
-	for _, t := range fw.Transitions {
-		startTimes = append(startTimes, t.From)
-	}
-	for _, s := range fw.Statics {
-		startTimes = append(startTimes, s.At)
-	}
-	mindiff := h24
-	// OK, have all start times, now to find the ones that are both positive and smallest
-	for _, st := range startTimes {
-		//diff := st.Sub(et)
-		diff := event.ToToday(et).Sub(event.ToToday(st))
-		if diff > 0 && diff < mindiff {
-			mindiff = diff
+	occurrences, err := fw.Occurrences(et, et.Add(h24))
+	if err != nil {
+		return h24
+	}
+	for _, o := range occurrences {
+		if o.From.After(et) {
+			return o.From.Sub(et)
 		}
 	}
-	return mindiff
+	return h24
 }
 
 // NextEvent finds the next event, given a timestamp.
 // Returns an interface{} that is either a static or transition event.
 func (fw *FatWallpaper) NextEvent(now time.Time) (interface{}, error) {
-	// Create a map, from timestamps to wallpaper events
-	events := make(map[time.Time]interface{})
-	for _, t := range fw.Transitions {
-		events[t.From] = t
-	}
-	for _, s := range fw.Statics {
-		events[s.At] = s
-	}
-	if len(events) == 0 {
-		return nil, errors.New("can not find next event: got no events")
+	occurrences, err := fw.Occurrences(now, now.Add(h24))
+	if err != nil {
+		return nil, fmt.Errorf("can not find next event: %s", err)
 	}
-	// Go though all the event time stamps, and find the one that has the smallest (now time - event time)
-	minDiff := h24
-	var minEvent interface{}
-	for t, e := range events {
-		//fmt.Printf("now is: %v (%T)\n", now, now)
-		//fmt.Printf("t is: %v (%T)\n", t, t)
-		diff := event.ToToday(t).Sub(event.ToToday(now))
-		//fmt.Println("Diff for", cFmt(t), ":", diff)
-		if diff > 0 && diff < minDiff {
-			minDiff = diff
-			minEvent = e
-			//fmt.Println("NEW SMALLEST DIFF RIGHT AFTER", cFmt(now), cFmt(t), minDiff)
+	for _, o := range occurrences {
+		if o.From.After(now) {
+			return o.Event, nil
 		}
 	}
-	return minEvent, nil
+	return nil, errors.New("can not find next event")
 }
 
 // PrevEvent finds the previous event, given a timestamp.
 // Returns an interface{} that is either a static or transition event.
 func (fw *FatWallpaper) PrevEvent(now time.Time) (interface{}, error) {
-	// Create a map, from timestamps to wallpaper events
-	events := make(map[time.Time]interface{})
-	for _, t := range fw.Transitions {
-		events[t.From] = t
+	occurrences, err := fw.Occurrences(now.Add(-h24), now.Add(time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("can not find previous event: %s", err)
+	}
+	for i := len(occurrences) - 1; i >= 0; i-- {
+		if !occurrences[i].From.After(now) {
+			return occurrences[i].Event, nil
+		}
 	}
-	for _, s := range fw.Statics {
-		events[s.At] = s
+	return nil, errors.New("can not find previous event")
+}
+
+// Shown finds what is shown at the given time of day. The first returned
+// string is the image filename. If a transition is ongoing, the second
+// returned string is the image that is being transitioned to, and the
+// returned float64 is how far the transition has come, from 0 to 1.
+// GNOME timed wallpapers are converted to Simple Timed Wallpapers first.
+// The events that apply to the date of the given time are used, but
+// otherwise only the time of day is used, so transitions that follow the
+// elevation of the sun are shown as if they progress linearly.
+func (fw *FatWallpaper) Shown(at time.Time) (string, string, float64, error) {
+	stw := fw
+	if fw.GNOME {
+		var err error
+		stw, err = GnomeToSimple(fw)
+		if err != nil {
+			return "", "", 0, err
+		}
 	}
-	if len(events) == 0 {
-		return nil, errors.New("can not find previous event: got no events")
+	if stw.usesConditions() || stw.usesCron() {
+		// Only the lists of events are changed when selecting the events for a date
+		onDate := *stw
+		onDate.selectSchedule(at)
+		onDate.expandCron(at)
+		stw = &onDate
 	}
-	// Go though all the event time stamps, and find the one that has the smallest (now time - event time)
+	now := sinceMidnight(at)
+	// Find the event that started most recently
 	minDiff := h24
 	var minEvent interface{}
-	for t, e := range events {
-		if minEvent == nil {
-			minEvent = e
+	for _, s := range stw.Statics {
+		if diff := mod24(now - sinceMidnight(s.At)); diff < minDiff {
+			minDiff = diff
+			minEvent = s
 		}
-		//fmt.Printf("now is: %v (%T)\n", now, now)
-		//fmt.Printf("t is: %v (%T)\n", t, t)
-		diff1 := event.ToToday(now).Sub(event.ToToday(t))
-		diff2 := event.ToTomorrow(now).Sub(event.ToToday(t))
-		//fmt.Println("Diff for", cFmt(t), ":", diff)
-		if diff1 > 0 && diff1 < minDiff {
-			minDiff = diff1
-			minEvent = e
-			//fmt.Println("NEW SMALLEST DIFF RIGHT BEFORE", cFmt(now), cFmt(t), minDiff)
+	}
+	for _, t := range stw.Transitions {
+		if diff := mod24(now - sinceMidnight(t.From)); diff < minDiff {
+			minDiff = diff
+			minEvent = t
 		}
-		if diff2 > 0 && diff2 < minDiff {
-			minDiff = diff2
-			minEvent = e
-			//fmt.Println("NEW SMALLEST DIFF RIGHT BEFORE", cFmt(now), cFmt(t), minDiff)
+	}
+	switch e := minEvent.(type) {
+	case *Static:
+		return e.Filename, "", 0, nil
+	case *Transition:
+		window := e.Duration()
+		if minDiff >= window {
+			// The transition is complete
+			return e.ToFilename, "", 0, nil
 		}
-
+		ratio := float64(minDiff) / float64(window)
+		if e.Easing != nil {
+			// Easing curves may overshoot
+			ratio = math.Max(0, math.Min(1, e.Easing.Apply(ratio)))
+		}
+		return e.FromFilename, e.ToFilename, ratio, nil
 	}
-	return minEvent, nil
+	return "", "", 0, errors.New("can not find what is shown: got no events")
 }
 
-// SetInitialWallpaper will set the first wallpaper, before starting the event loop
-func (fw *FatWallpaper) SetInitialWallpaper(verbose bool, setWallpaperFunc func(string) error, tempImageFilename string) error {
-	e, err := fw.PrevEvent(time.Now())
-	if err != nil {
-		return err
+// setStatic sets the image of a static image event as the wallpaper
+func (fw *FatWallpaper) setStatic(verbose bool, setWallpaperFunc func(string) error, o *Occurrence) error {
+	imageFilename := o.Event.(*Static).Filename
+
+	if verbose {
+		fmt.Printf("Triggered static wallpaper event at %s\n", cFmt(o.From))
+		fmt.Println("Window:", dFmt(o.Window()))
+		fmt.Println("Filename:", imageFilename)
 	}
-	switch v := e.(type) {
-	case *Static:
-		s := v
-
-		// Place values into variables, before enclosing it in the function below.
-		from := s.At
-		//elapsed := time.Now().Sub(s.At)
-		elapsed := event.ToToday(time.Now()).Sub(event.ToToday(s.At))
-		if elapsed < 0 {
-			elapsed *= -1
-		}
-		window := mod24(fw.UntilNext(s.At) - elapsed) // duration until next event start, minus time elapsed
-		cooldown := window
 
-		imageFilename := s.Filename
+	// Find the absolute path
+	absImageFilename, err := filepath.Abs(imageFilename)
+	if err == nil {
+		imageFilename = absImageFilename
+	}
 
-		if verbose {
-			fmt.Printf("Initial static wallpaper event at %s\n", cFmt(from))
-			fmt.Println("Window:", dFmt(window))
-			fmt.Println("Cooldown:", dFmt(cooldown))
-			fmt.Println("Filename:", imageFilename)
-		}
+	// Check that the file exists
+	if _, err := os.Stat(imageFilename); os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist: %s", imageFilename)
+	}
 
-		// Find the absolute path
-		absImageFilename, err := filepath.Abs(imageFilename)
-		if err == nil {
-			imageFilename = absImageFilename
-		}
+	// Set the desktop wallpaper, if possible
+	if verbose {
+		fmt.Printf("Setting %s.\n", imageFilename)
+	}
+	setmut.Lock()
+	defer setmut.Unlock()
+	if err := setWallpaperFunc(imageFilename); err != nil {
+		return fmt.Errorf("could not set wallpaper: %v", err)
+	}
+	return nil
+}
 
-		// Check that the file exists
-		if _, err := os.Stat(imageFilename); os.IsNotExist(err) {
-			return fmt.Errorf("file does not exist: %s", imageFilename)
-		}
+// setTransition crossfades the two images of a transition event, according
+// to how far the transition has come, and sets the result as the wallpaper
+func (fw *FatWallpaper) setTransition(verbose bool, setWallpaperFunc func(string) error, frames *FrameWriter, o *Occurrence) error {
+	t := o.Event.(*Transition)
+	now := time.Now()
+	ratio := fw.progress(o, now)
 
-		// Set the desktop wallpaper, if possible
-		if verbose {
-			fmt.Printf("Setting %s.\n", imageFilename)
-		}
-		if err := setWallpaperFunc(imageFilename); err != nil {
-			return fmt.Errorf("could not set wallpaper: %v", err)
-		}
+	if verbose {
+		fmt.Printf("Triggered transition event at %s (%d%% complete)\n", cFmt(o.From), int(ratio*100))
+		fmt.Println("Progress:", dFmt(now.Sub(o.From)))
+		fmt.Println("Up to:", cFmt(o.UpTo))
+		fmt.Println("Window:", dFmt(o.Window()))
+		fmt.Println("Loop wait:", dFmt(fw.LoopWait))
+		fmt.Println("Transition type:", t.Type)
+		fmt.Println("From filename", t.FromFilename)
+		fmt.Println("To filename", t.ToFilename)
+		fmt.Println("Crossfading between images.")
+	}
 
-		// Just sleep for half the cooldown, to have some time to register events too
-		if verbose {
-			fmt.Println("Activating events in", dFmt(cooldown/2))
+	var frameFilename string
+	if fw.FrameCache != nil {
+		// Use a frame that has been rendered ahead of time, or render it now
+		filename, err := fw.FrameCache.Frame(fw, t, ratio)
+		if err != nil {
+			return fmt.Errorf("could not crossfade images in transition: %v", err)
 		}
-		time.Sleep(cooldown / 2)
-	case *Transition:
-		t := v
-
-		now := time.Now()
-		window := t.Duration()
-		progress := mod24(window - event.ToToday(t.UpTo).Sub(event.ToToday(now)))
-		ratio := float64(progress) / float64(window)
-		from := t.From
-		steps := 10
-		cooldown := window / time.Duration(steps)
-		upTo := from.Add(window)
-		tType := t.Type
-		tFromFilename := t.FromFilename
-		tToFilename := t.ToFilename
-		loopWait := fw.LoopWait
+		setmut.Lock()
+		defer setmut.Unlock()
+		frameFilename = filename
+	} else {
+		// Write the new image to the temporary directory
+		setmut.Lock()
+		defer setmut.Unlock()
+		ff := fw.frameFormat()
 		var err error
-
-		if verbose {
-			fmt.Printf("Initial transition event at %s (%d%% complete)\n", cFmt(from), int(ratio*100))
-			fmt.Println("Progress:", dFmt(progress))
-			fmt.Println("Up to:", cFmt(upTo))
-			fmt.Println("Window:", dFmt(window))
-			fmt.Println("Cooldown:", dFmt(cooldown))
-			fmt.Println("Loop wait:", dFmt(loopWait))
-			fmt.Println("Transition type:", tType)
-			fmt.Println("From filename", tFromFilename)
-			fmt.Println("To filename", tToFilename)
+		frameFilename, err = frames.WriteFunc(ff.Ext(), func(w io.Writer) error {
+			return t.writeFrame(w, ratio, ff, fw.Dither)
+		})
+		if err != nil {
+			return fmt.Errorf("could not crossfade images in transition: %v", err)
 		}
+	}
 
-		// Set the "from" image before crossfading, so that something happens immediately
+	// Double check that the generated file exists
+	if _, err := os.Stat(frameFilename); os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist: %s", frameFilename)
+	}
 
-		// Set the desktop wallpaper, if possible
-		if verbose {
-			fmt.Printf("Setting %s.\n", tFromFilename)
-		}
-		if err := setWallpaperFunc(tFromFilename); err != nil {
-			return fmt.Errorf("could not set wallpaper: %v", err)
-		}
+	// Set the desktop wallpaper, if possible
+	if verbose {
+		fmt.Printf("Setting %s.\n", frameFilename)
+	}
+	if err := setWallpaperFunc(frameFilename); err != nil {
+		return fmt.Errorf("could not set wallpaper: %v", err)
+	}
+	return nil
+}
 
-		if verbose {
-			fmt.Println("Crossfading between images.")
-		}
+// frameImage returns the image that is shown by the given event at the
+// given time, where transitions are rendered according to their type
+func (fw *FatWallpaper) frameImage(o *Occurrence, now time.Time) (image.Image, error) {
+	switch v := o.Event.(type) {
+	case *Static:
+		return DefaultImageCache.Open(v.Filename)
+	case *Transition:
+		return v.renderFrame(fw.progress(o, now))
+	}
+	return nil, errors.New("unknown event type")
+}
 
-		tFromImg, err := imgio.Open(tFromFilename)
+// setBlended blends what is shown by the events for the season with what
+// is shown by the events for the season that is blended in, by the given
+// ratio, and sets the result as the wallpaper
+func (fw *FatWallpaper) setBlended(verbose bool, setWallpaperFunc func(string) error, frames *FrameWriter, occurrences, blended []*Occurrence, ratio float64) error {
+	now := time.Now()
+	o, err := ongoing(occurrences, now)
+	if err != nil {
+		return err
+	}
+	b, err := ongoing(blended, now)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Printf("Blending the events for two seasons (%d%% of the next or previous season)\n", int(ratio*100))
+	}
+	img, err := fw.frameImage(o, now)
+	if err != nil {
+		return err
+	}
+	blendedImg, err := fw.frameImage(b, now)
+	if err != nil {
+		return err
+	}
+
+	// Blend and write the new image to the temporary directory
+	setmut.Lock()
+	defer setmut.Unlock()
+	ff := fw.frameFormat()
+	frameFilename, err := frames.WriteFunc(ff.Ext(), func(w io.Writer) error {
+		return DefaultBlender.Encode(w, img, blendedImg, ratio, LinearLight, fw.Dither, ff)
+	})
+	if err != nil {
+		return fmt.Errorf("could not blend the seasons: %v", err)
+	}
+	if verbose {
+		fmt.Printf("Setting %s.\n", frameFilename)
+	}
+	if err := setWallpaperFunc(frameFilename); err != nil {
+		return fmt.Errorf("could not set wallpaper: %v", err)
+	}
+	return nil
+}
+
+// SetInitialWallpaper will set the first wallpaper, before starting the event loop.
+// Blended frames are written next to the given temporary image filename,
+// alternating between two filenames, like "frame-0.jpg" and "frame-1.jpg"
+// for "frame.jpg". If the filename has no directory, $XDG_RUNTIME_DIR/timed
+// is used. The frames are not removed afterwards, since one of them is the
+// wallpaper, but the next call overwrites them.
+func (fw *FatWallpaper) SetInitialWallpaper(verbose bool, setWallpaperFunc func(string) error, tempImageFilename string) error {
+	frames, err := frameWriterAt(tempImageFilename)
+	if err != nil {
+		return err
+	}
+	return fw.setInitialWallpaper(verbose, setWallpaperFunc, frames)
+}
+
+// setInitialWallpaper sets the wallpaper that is shown now
+func (fw *FatWallpaper) setInitialWallpaper(verbose bool, setWallpaperFunc func(string) error, frames *FrameWriter) error {
+	now := time.Now()
+	if _, ratio := fw.seasonBlend(now); ratio > 0 {
+		occurrences, err := fw.Occurrences(now, now.Add(time.Second))
 		if err != nil {
-			return err
+			return fmt.Errorf("could not set initial wallpaper: %s", err)
 		}
-
-		tToImg, err := imgio.Open(tToFilename)
+		blended, err := fw.blendedOccurrences(now, now.Add(time.Second))
 		if err != nil {
-			return err
+			return fmt.Errorf("could not set initial wallpaper: %s", err)
+		}
+		return fw.setBlended(verbose, setWallpaperFunc, frames, occurrences, blended, ratio)
+	}
+	o, err := fw.occurrenceAt(now)
+	if err != nil {
+		return fmt.Errorf("could not set initial wallpaper: %s", err)
+	}
+	switch v := o.Event.(type) {
+	case *Static:
+		return fw.setStatic(verbose, setWallpaperFunc, o)
+	case *Transition:
+		// Set the "from" image before crossfading, so that something happens immediately
+		if verbose {
+			fmt.Printf("Setting %s.\n", v.FromFilename)
 		}
-
-		// Crossfade and write the new image to the temporary directory
 		setmut.Lock()
-		blendedImage := blend.Opacity(tFromImg, tToImg, ratio)
-		err = imgio.Save(tempImageFilename, blendedImage, imgio.JPEGEncoder(100))
+		err := setWallpaperFunc(v.FromFilename)
+		setmut.Unlock()
 		if err != nil {
-			setmut.Unlock()
-			return fmt.Errorf("could not crossfade images in transition: %v", err)
+			return fmt.Errorf("could not set wallpaper: %v", err)
 		}
-		setmut.Unlock()
+		return fw.setTransition(verbose, setWallpaperFunc, frames, o)
+	}
+	return errors.New("could not set initial wallpaper: no previous event")
+}
+
+// initialCooldown returns how long to wait after the initial wallpaper has
+// been set, before the events are activated: half the cooldown of the
+// ongoing event, so that it does not set the same wallpaper right away
+func (fw *FatWallpaper) initialCooldown(now time.Time) time.Duration {
+	o, err := fw.occurrenceAt(now)
+	if err != nil {
+		return 0
+	}
+	cooldown := o.UpTo.Sub(now)
+	if _, ok := o.Event.(*Transition); ok {
+		cooldown = o.Window() / time.Duration(transitionSteps)
+	}
+	return cooldown / 2
+}
+
+// dayLoop creates an event loop with the events that are ongoing at the
+// date of the given time, in the time zone of the timed wallpaper. If the
+// event loop of the day before was running, the events that started before
+// midnight are only triggered when they would have been triggered again.
+func (fw *FatWallpaper) dayLoop(verbose bool, setWallpaperFunc func(string) error, frames *FrameWriter, running *actions, now time.Time, resumed bool) (*event.Loop, error) {
+	now = now.In(fw.location())
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, fw.location())
+	dayEnd := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, fw.location())
+	occurrences, err := fw.Occurrences(dayStart, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	eventloop := event.NewLoop()
 
-		// Double check that the generated file exists
-		if _, err := os.Stat(tempImageFilename); os.IsNotExist(err) {
-			return fmt.Errorf("file does not exist: %s", tempImageFilename)
+	// add registers an event for the given occurrence
+	add := func(o *Occurrence, cooldown time.Duration, action func()) {
+		from := o.From
+		if resumed {
+			var ok bool
+			if from, ok = o.resumeAt(dayStart, cooldown); !ok {
+				return
+			}
 		}
+		eventloop.Add(event.NewDateEvent(from, o.UpTo.Sub(from), cooldown, func() {
+			running.run(action)
+		}))
+	}
 
-		// Set the desktop wallpaper, if possible
+	// When the events for two seasons are blended, every change in either of
+	// them changes the blended wallpaper
+	if _, ratio := fw.seasonBlend(dayStart); ratio > 0 {
+		blended, err := fw.blendedOccurrences(dayStart, dayEnd)
+		if err != nil {
+			return nil, err
+		}
 		if verbose {
-			fmt.Printf("Setting %s.\n", tempImageFilename)
+			fmt.Printf("Blending %d%% of the events for the next or previous season\n", int(ratio*100))
 		}
-		setmut.Lock()
-		if err := setWallpaperFunc(tempImageFilename); err != nil {
-			setmut.Unlock()
-			return fmt.Errorf("could not set wallpaper: %v", err)
+		for _, o := range append(occurrences, blended...) {
+			// Let transitions update the wallpaper as often as they would by themselves
+			cooldown := o.Window()
+			if _, ok := o.Event.(*Transition); ok {
+				cooldown /= time.Duration(transitionSteps)
+			}
+			add(o, cooldown, func() {
+				if err := fw.setBlended(verbose, setWallpaperFunc, frames, occurrences, blended, ratio); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			})
 		}
-		setmut.Unlock()
+		return eventloop, nil
+	}
 
-		// Just sleep for half the cooldown, to have some time to register events too
-		if verbose {
-			fmt.Println("Activating events in", dFmt(cooldown/2))
+	for _, o := range occurrences {
+		o := o // enclosed in the functions below
+		switch v := o.Event.(type) {
+		case *Static:
+			if verbose {
+				fmt.Printf("Registering static event at %s for setting %s\n", o.From.Format("2006-01-02 15:04:05"), v.Filename)
+			}
+			// Register a static event, that only triggers once
+			add(o, o.Window(), func() {
+				if err := fw.setStatic(verbose, setWallpaperFunc, o); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			})
+		case *Transition:
+			if verbose {
+				fmt.Printf("Registering transition at %s for transitioning from %s to %s.\n", o.From.Format("2006-01-02 15:04:05"), v.FromFilename, v.ToFilename)
+			}
+			// cross fade steps
+			cooldown := o.Window() / time.Duration(transitionSteps)
+			// Register a transition event
+			add(o, cooldown, func() {
+				if err := fw.setTransition(verbose, setWallpaperFunc, frames, o); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			})
 		}
-		time.Sleep(cooldown / 2)
-	default:
-		return errors.New("could not set initial wallpaper: no previous event")
 	}
-	return nil
+	return eventloop, nil
 }
 
-// EventLoop will start the event loop for this Simple Timed Wallpaper
+// EventLoop will start the event loop for this Simple Timed Wallpaper.
+// Blended frames are written to a new directory within
+// $XDG_RUNTIME_DIR/timed, or within the cache directory of the user, in the
+// frame format of the timed wallpaper. They alternate between two
+// filenames that are named after the given temporary image filename, like
+// "frame-0.jpg" and "frame-1.jpg" for "frame.jpg", or "timed-0.jpg" and
+// "timed-1.jpg" if the filename is "". The event loop returns when SIGINT
+// or SIGTERM is received, after waiting for the events that are being
+// triggered and removing the frames. If the timed wallpaper has a frame
+// cache, the frames of the transitions are rendered to the cache in the
+// background, and are used from there instead.
 func (fw *FatWallpaper) EventLoop(verbose bool, setWallpaperFunc func(string) error, tempImageFilename string) error {
 	if verbose {
 		if fw.Config != nil {
@@ -263,337 +469,120 @@ func (fw *FatWallpaper) EventLoop(verbose bool, setWallpaperFunc func(string) er
 		}
 	}
 
-	var err error
-	initialW := fw
-	if fw.Config != nil {
-		initialW, err = GnomeToSimple(fw)
-		if err != nil {
-			return err
-		}
+	// Convert to a Simple Timed Wallpaper, if needed
+	stw, err := fw.toSimple()
+	if err != nil {
+		return err
+	}
+
+	frames, err := NewFrameWriter(tempImageFilename)
+	if err != nil {
+		return err
+	}
+	running := &actions{}
+	defer func() {
+		// No frames may be written after they are removed
+		running.stop()
+		frames.Cleanup()
+	}()
+
+	// Render the frames of the transitions in the background, if there is a frame cache
+	if stw.FrameCache != nil {
+		go func() {
+			if err := stw.Prerender(stw.FrameCache); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+			if err := stw.FrameCache.GC(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+		}()
 	}
 
+	// Listen for SIGINT or SIGTERM, to stop the event loop
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
 	// Listen for SIGHUP or SIGUSR1, to refresh the wallpaper.
 	// Can be used after resume from sleep.
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGHUP, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
 		for {
-			// Wait for a signal of the type given to signal.Notify
-			sig := <-signals
+			// Wait for a signal of the type given to signal.Notify, or for the event loop to stop
+			var sig os.Signal
+			select {
+			case sig = <-signals:
+			case <-done:
+				return
+			}
 			// Refresh the wallpaper
 			fmt.Println("Received signal", sig)
 			// Launch a goroutine for setting the wallpaper
-			go func() {
-				setmut.Lock()
-				// Convert to a SimpleTimedWallpaper, only for setting the initial wallpaper
-
-				if err := initialW.SetInitialWallpaper(verbose, setWallpaperFunc, tempImageFilename); err != nil {
+			go running.run(func() {
+				if err := stw.setInitialWallpaper(verbose, setWallpaperFunc, frames); err != nil {
 					fmt.Fprintln(os.Stderr, "Error:", err)
 				}
-				setmut.Unlock()
-			}()
+			})
 		}
 	}()
 
-	setmut.Lock()
-	if err := initialW.SetInitialWallpaper(verbose, setWallpaperFunc, tempImageFilename); err != nil {
-		setmut.Unlock()
+	if err := stw.setInitialWallpaper(verbose, setWallpaperFunc, frames); err != nil {
 		return err
 	}
-	setmut.Unlock()
-
-	eventloop := event.NewLoop()
-
-	if fw.Config != nil {
 
-		// Get the start time for the wallpaper collection (which is offset by X
-		// seconds per static wallpaper)
-		startTime := fw.StartTime()
-
-		// The start time of the timed wallpaper as a whole
+	// Just wait for half the cooldown, to have some time to register events too
+	cooldown := stw.initialCooldown(time.Now())
+	if verbose {
+		fmt.Println("Activating events in", dFmt(cooldown))
+	}
+	select {
+	case sig := <-stop:
 		if verbose {
-			fmt.Println("Timed wallpaper start time:", cFmt(startTime))
+			fmt.Println("Received signal", sig)
 		}
+		return nil
+	case <-time.After(cooldown):
+	}
 
-		totalElements := len(fw.Config.Statics) + len(fw.Config.Transitions)
-
-		// Keep track of the total time. It is increased every time a new element duration is encountered.
-		eventTime := startTime
-
-		for i := 0; i < totalElements; i++ {
-			// The duration of the event is specified in the XML file, but not when it should start
-
-			// Get an element, by index. This is an interface{} and is expected to be a GStatic or a GTransition
-			eInterface, err := fw.Config.Get(i)
+	// Endless loop! Will wait LoopWait duration between each event loop cycle.
+	// The events are placed at concrete dates, and are registered again every day.
+	var (
+		eventloop *event.Loop
+		day       string
+	)
+	for {
+		now := time.Now().In(stw.location())
+		if today := now.Format("2006-01-02"); eventloop == nil || today != day {
+			// The events that are ongoing at midnight have already been triggered by the loop of the day before
+			resumed := eventloop != nil
+			day = today
+			eventloop, err = stw.dayLoop(verbose, setWallpaperFunc, frames, running, now, resumed)
 			if err != nil {
 				return err
 			}
-			if s, ok := eInterface.(GStatic); ok {
-				if verbose {
-					fmt.Printf("Registering static event at %s for setting %s\n", cFmt(eventTime), s.Filename)
-				}
-
-				// Place values into variables, before enclosing it in the function below.
-				from := eventTime
-				window := s.Duration()
-				cooldown := window
-				imageFilename := s.Filename
-
-				// Register a static event
-				eventloop.Add(event.New(from, window, cooldown, func() {
-					if verbose {
-						fmt.Printf("Triggered static wallpaper event at %s\n", cFmt(from))
-						fmt.Println("Window:", dFmt(window))
-						fmt.Println("Cooldown:", dFmt(cooldown))
-						fmt.Println("Filename:", imageFilename)
-					}
-
-					// Find the absolute path
-					absImageFilename, err := filepath.Abs(imageFilename)
-					if err == nil {
-						imageFilename = absImageFilename
-					}
-
-					// Check that the file exists
-					if _, err := os.Stat(imageFilename); os.IsNotExist(err) {
-						fmt.Fprintf(os.Stderr, "File does not exist: %s\n", imageFilename)
-						return // return from anon func
-					}
-
-					// Set the desktop wallpaper, if possible
-					if verbose {
-						fmt.Printf("Setting %s.\n", imageFilename)
-					}
-					if err := setWallpaperFunc(imageFilename); err != nil {
-						fmt.Fprintf(os.Stderr, "Could not set wallpaper: %v\n", err)
-						return // return from anon func
-					}
-				}))
-
-				// Increase the variable that keeps track of the time
-				eventTime = eventTime.Add(window)
-
-			} else if t, ok := eInterface.(GTransition); ok {
-				if verbose {
-					fmt.Printf("Registering transition at %s for transitioning from %s to %s.\n", cFmt(eventTime), t.FromFilename, t.ToFilename)
-				}
-
-				// cross fade steps
-				steps := 10
-
-				from := eventTime
-				window := t.Duration()
-				upTo := eventTime.Add(window)
-				cooldown := window / time.Duration(steps)
-				tType := t.Type
-				tFromFilename := t.FromFilename
-				tToFilename := t.ToFilename
-				loopWait := fw.LoopWait
-
-				// Register a transition event
-				eventloop.Add(event.New(from, window, cooldown, func() {
-					progress := mod24(window - event.ToToday(upTo).Sub(event.ToToday(time.Now())))
-					ratio := float64(progress) / float64(window)
-
-					if verbose {
-						fmt.Printf("Triggered transition event at %s (%d%% complete)\n", cFmt(from), int(ratio*100))
-						fmt.Println("Progress:", dFmt(progress))
-						fmt.Println("Up to:", cFmt(upTo))
-						fmt.Println("Window:", dFmt(window))
-						fmt.Println("Cooldown:", dFmt(cooldown))
-						fmt.Println("Loop wait:", dFmt(loopWait))
-						fmt.Println("Transition type:", tType)
-						fmt.Println("From filename", tFromFilename)
-						fmt.Println("To filename", tToFilename)
-					}
-
-					if verbose {
-						fmt.Println("Crossfading between images.")
-					}
-
-					// Crossfade and write the new image to the temporary directory
-					tFromImg, err := imgio.Open(tFromFilename)
-					if err != nil {
-						fmt.Fprintln(os.Stderr, err)
-						return
-					}
-
-					tToImg, err := imgio.Open(tToFilename)
-					if err != nil {
-						fmt.Fprintln(os.Stderr, err)
-						return
-					}
-
-					// Crossfade and write the new image to the temporary directory
-					setmut.Lock()
-					blendedImage := blend.Opacity(tFromImg, tToImg, ratio)
-					err = imgio.Save(tempImageFilename, blendedImage, imgio.JPEGEncoder(100))
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Could not crossfade images in transition: %v\n", err)
-						setmut.Unlock()
-						return
-					}
-					setmut.Unlock()
-
-					// Double check that the generated file exists
-					if _, err := os.Stat(tempImageFilename); os.IsNotExist(err) {
-						fmt.Fprintf(os.Stderr, "File does not exist: %s\n", tempImageFilename)
-						return // return from anon func
-					}
-
-					// Set the desktop wallpaper, if possible
-					if verbose {
-						fmt.Printf("Setting %s.\n", tempImageFilename)
-					}
-					if err := setWallpaperFunc(tempImageFilename); err != nil {
-						fmt.Fprintf(os.Stderr, "Could not set wallpaper: %v\n", err)
-						return // return from anon func
-					}
-
-				}))
-
-				// Increase the variable that keeps track of the time
-				eventTime = eventTime.Add(window)
-			} else {
-				// This should never happen, it would be an implementation error
-				panic("got an element that is not a GStatic and not a GTransition")
-			}
 		}
-
-		// Endless loop! Will wait loopWait duration between each event loop cycle.
-		eventloop.Go(fw.LoopWait)
-
-	} else {
-
-		for _, s := range fw.Statics {
-			if verbose {
-				fmt.Printf("Registering static event at %s for setting %s\n", cFmt(s.At), s.Filename)
+		// This is like event.Loop.Go, which can not be used here, since it
+		// never returns and the events would not be registered again every day.
+		// For each possible event
+		for _, e := range *eventloop {
+			// Check if the event should trigger
+			if e.ShouldTrigger() {
+				// When triggering an event, run it in the background
+				go e.Trigger()
 			}
-
-			// Place values into variables, before enclosing it in the function below.
-			from := s.At
-			window := mod24(fw.UntilNext(s.At)) // duration until next event start
-			cooldown := window
-			imageFilename := s.Filename
-
-			// Register a static event
-			eventloop.Add(event.New(from, window, cooldown, func() {
-				if verbose {
-					fmt.Printf("Triggered static wallpaper event at %s\n", cFmt(from))
-					fmt.Println("Window:", dFmt(window))
-					fmt.Println("Cooldown:", dFmt(cooldown))
-					fmt.Println("Filename:", imageFilename)
-				}
-
-				// Find the absolute path
-				absImageFilename, err := filepath.Abs(imageFilename)
-				if err == nil {
-					imageFilename = absImageFilename
-				}
-
-				// Check that the file exists
-				if _, err := os.Stat(imageFilename); os.IsNotExist(err) {
-					fmt.Fprintf(os.Stderr, "File does not exist: %s\n", imageFilename)
-					return // return from anon func
-				}
-
-				// Set the desktop wallpaper, if possible
-				if verbose {
-					fmt.Printf("Setting %s.\n", imageFilename)
-				}
-				if err := setWallpaperFunc(imageFilename); err != nil {
-					fmt.Fprintf(os.Stderr, "Could not set wallpaper: %v\n", err)
-					return // return from anon func
-				}
-			}))
 		}
-		for _, t := range fw.Transitions {
+		// How long to sleep before checking again, unless the event loop is stopped
+		select {
+		case sig := <-stop:
 			if verbose {
-				fmt.Printf("Registering transition at %s for transitioning from %s to %s.\n", cFmt(t.From), t.FromFilename, t.ToFilename)
+				fmt.Println("Received signal", sig)
 			}
-
-			// cross fade steps
-			steps := 10
-
-			// Set variables
-			from := t.From
-			window := t.Duration()
-			cooldown := window / time.Duration(steps)
-			upTo := from.Add(window)
-			tType := t.Type
-			tFromFilename := t.FromFilename
-			tToFilename := t.ToFilename
-			loopWait := fw.LoopWait
-
-			// Register a transition event
-			//eventloop.Add(event.New(from, window, cooldown, event.ProgressWrapperInterval(from, upTo, loopWait, func(ratio float64) {
-			eventloop.Add(event.New(from, window, cooldown, func() {
-				progress := mod24(window - event.ToToday(upTo).Sub(event.ToToday(time.Now())))
-				ratio := float64(progress) / float64(window)
-
-				if verbose {
-					fmt.Printf("Triggered transition event at %s (%d%% complete)\n", cFmt(from), int(ratio*100))
-					fmt.Println("Progress:", dFmt(progress))
-					fmt.Println("Up to:", cFmt(upTo))
-					fmt.Println("Window:", dFmt(window))
-					fmt.Println("Cooldown:", dFmt(cooldown))
-					fmt.Println("Loop wait:", dFmt(loopWait))
-					fmt.Println("Transition type:", tType)
-					fmt.Println("From filename", tFromFilename)
-					fmt.Println("To filename", tToFilename)
-				}
-
-				if verbose {
-					fmt.Println("Crossfading between images.")
-				}
-
-				// Crossfade and write the new image to the temporary directory
-				tFromImg, err := imgio.Open(tFromFilename)
-				if err != nil {
-					fmt.Fprintln(os.Stderr, err)
-					return
-				}
-
-				tToImg, err := imgio.Open(tToFilename)
-				if err != nil {
-					fmt.Fprintln(os.Stderr, err)
-					return
-				}
-
-				// Crossfade and write the new image to the temporary directory
-				setmut.Lock()
-				blendedImage := blend.Opacity(tFromImg, tToImg, ratio)
-				err = imgio.Save(tempImageFilename, blendedImage, imgio.JPEGEncoder(100))
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Could not crossfade images in transition: %v\n", err)
-					setmut.Unlock()
-					return
-				}
-				setmut.Unlock()
-
-				// Double check that the generated file exists
-				if _, err := os.Stat(tempImageFilename); os.IsNotExist(err) {
-					fmt.Fprintf(os.Stderr, "File does not exist: %s\n", tempImageFilename)
-					return // return from anon func
-				}
-
-				// Set the desktop wallpaper, if possible
-				if verbose {
-					fmt.Printf("Setting %s.\n", tempImageFilename)
-				}
-				setmut.Lock()
-				if err := setWallpaperFunc(tempImageFilename); err != nil {
-					setmut.Unlock()
-					fmt.Fprintf(os.Stderr, "Could not set wallpaper: %v\n", err)
-					return // return from anon func
-				}
-				setmut.Unlock()
-			}))
+			return nil
+		case <-time.After(stw.LoopWait):
 		}
-
-		// Endless loop! Will wait LoopWait duration between each event loop cycle.
-		eventloop.Go(fw.LoopWait)
 	}
-
-	return nil
 }
@@ -0,0 +1,180 @@
+package timed
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// formatPlaceholder returns the placeholder that is replaced by the name of
+// the image in the given format string. Version 1.0 of the format uses "%s",
+// while later versions may also use "{}".
+func formatPlaceholder(format string) string {
+	if strings.Contains(format, "{}") {
+		return "{}"
+	}
+	return "%s"
+}
+
+// applyFormat returns the filename for the given image name, by replacing
+// the placeholders in the given format string with the name. If the format
+// string has no placeholders, the name is returned as it is.
+func applyFormat(format, name string) string {
+	placeholder := formatPlaceholder(format)
+	if !strings.Contains(format, placeholder) {
+		return name
+	}
+	return strings.Replace(format, placeholder, name, -1)
+}
+
+// unapplyFormat finds the image name that gives the given filename when the
+// given format string is applied. Returns false if there is no such name.
+func unapplyFormat(format, filename string) (string, bool) {
+	placeholder := formatPlaceholder(format)
+	if !strings.Contains(format, placeholder) {
+		return filename, true
+	}
+	parts := strings.Split(format, placeholder)
+	n := len(parts) - 1
+	rest := len(filename) - (len(format) - n*len(placeholder))
+	if rest <= 0 || rest%n != 0 {
+		return "", false
+	}
+	name := filename[len(parts[0]) : len(parts[0])+rest/n]
+	if applyFormat(format, name) != filename {
+		return "", false
+	}
+	return name, true
+}
+
+// isVariableName checks if the given string can be used as a variable name
+func isVariableName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// expandVariables replaces every "{name}" in the given string with the
+// value of the variable with that name. "{}" is kept as it is, since it is
+// the placeholder for the image name.
+func expandVariables(s string, variables map[string]string) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.Index(s, "{")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end == -1 {
+			return "", fmt.Errorf("missing } in: %s", s)
+		}
+		end += start
+		sb.WriteString(s[:start])
+		name := s[start+1 : end]
+		if name == "" {
+			sb.WriteString("{}")
+		} else if value, ok := variables[name]; ok {
+			sb.WriteString(value)
+		} else {
+			return "", fmt.Errorf("unknown variable: %s", name)
+		}
+		s = s[end+1:]
+	}
+	sb.WriteString(s)
+	return sb.String(), nil
+}
+
+// parseSet parses a line that sets a variable, like "set theme = mojave".
+// The value may use variables that are already set.
+func parseSet(line string, variables map[string]string) (string, string, error) {
+	fields := strings.SplitN(strings.TrimSpace(line[len("set"):]), "=", 2)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("missing = in: %s", line)
+	}
+	name := strings.TrimSpace(fields[0])
+	if !isVariableName(name) {
+		return "", "", fmt.Errorf("invalid variable name: %s", name)
+	}
+	value, err := expandVariables(strings.TrimSpace(fields[1]), variables)
+	if err != nil {
+		return "", "", err
+	}
+	return name, value, nil
+}
+
+// expandedFormat returns the format string of the timed wallpaper, where
+// the variables have been replaced by their values
+func (fw *FatWallpaper) expandedFormat() string {
+	format, err := expandVariables(fw.Format, fw.Variables)
+	if err != nil {
+		return fw.Format
+	}
+	return format
+}
+
+// variableLines returns the lines that set the variables of the timed
+// wallpaper, sorted by name
+func (fw *FatWallpaper) variableLines() []string {
+	var names []string
+	for name := range fw.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("set %s = %s", name, fw.Variables[name]))
+	}
+	return lines
+}
+
+// matchesFormat checks if the filenames of an event can be written with the
+// given format string
+func matchesFormat(e interface{}, format string) bool {
+	var filenames []string
+	switch v := e.(type) {
+	case *Static:
+		filenames = []string{v.Filename}
+	case *Transition:
+		filenames = []string{v.FromFilename, v.ToFilename}
+	}
+	for _, filename := range filenames {
+		if _, ok := unapplyFormat(format, filename); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// eventSource returns the included file that a *Static or *Transition is
+// from, or "" if it is from the file itself
+func eventSource(e interface{}) string {
+	switch v := e.(type) {
+	case *Static:
+		return v.Source
+	case *Transition:
+		return v.Source
+	}
+	return ""
+}
+
+// usesFormatExtensions checks if the timed wallpaper uses variables, a
+// format string with "{}" or events that can not be written with the
+// format string
+func (fw *FatWallpaper) usesFormatExtensions() bool {
+	if len(fw.Variables) > 0 || strings.Contains(fw.Format, "{") || strings.Count(fw.Format, "%s") > 1 {
+		return true
+	}
+	format := fw.expandedFormat()
+	for _, e := range fw.events() {
+		if eventSource(e) == "" && !matchesFormat(e, format) {
+			return true
+		}
+	}
+	return false
+}
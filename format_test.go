@@ -0,0 +1,82 @@
+package timed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatVariables(t *testing.T) {
+	data := []byte("stw: 1.0\nset theme = mojave\nset size = 5k\nformat: /usr/share/backgrounds/{theme}/{theme}_dynamic-{}.jpg\n@00:00: night\n@06:00-08:00: night .. day\n@08:00: day\nformat: /usr/share/backgrounds/{theme}/{size}/%s.png\n@12:00: noon\n")
+	stw, err := DataToSimple("variables.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.Validate(); err != nil {
+		t.Error(err)
+	}
+	var images []string
+	for _, s := range stw.Statics {
+		images = append(images, s.Filename)
+	}
+	expected := []string{
+		"/usr/share/backgrounds/mojave/mojave_dynamic-night.jpg",
+		"/usr/share/backgrounds/mojave/mojave_dynamic-day.jpg",
+		"/usr/share/backgrounds/mojave/5k/noon.png",
+	}
+	for _, filename := range expected {
+		found := false
+		for _, image := range images {
+			found = found || image == filename
+		}
+		if !found {
+			t.Errorf("expected %s in %v", filename, images)
+		}
+	}
+
+	// The variables and the format are written back, and the events that
+	// do not fit the format are written after an empty format field
+	s := stw.String()
+	for _, line := range []string{"stw: 1.2", "set size = 5k", "set theme = mojave", "format: /usr/share/backgrounds/{theme}/{theme}_dynamic-{}.jpg", "@08:00: day", "format:\n@12:00: /usr/share/backgrounds/mojave/5k/noon.png"} {
+		if !strings.Contains(s, line) {
+			t.Errorf("expected %q in:\n%s", line, s)
+		}
+	}
+	stw2, err := DataToSimple("variables.stw", []byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Equivalent(stw, stw2, 0); err != nil || !ok {
+		t.Errorf("expected the timed wallpaper to survive a round trip, got:\n%s", stw2)
+	}
+
+	// Unknown variables are errors
+	if _, err := DataToSimple("unknown.stw", []byte("stw: 1.0\nformat: /usr/share/{theme}/{}.jpg\n@00:00: night\n")); err == nil || !strings.Contains(err.Error(), "unknown variable: theme") {
+		t.Errorf("expected an unknown variable error, got %v", err)
+	}
+}
+
+func TestUnapplyFormat(t *testing.T) {
+	if name, ok := unapplyFormat("/a/{}/{}_x-{}.jpg", "/a/day/day_x-day.jpg"); !ok || name != "day" {
+		t.Errorf("expected day, got %q", name)
+	}
+	if _, ok := unapplyFormat("/a/{}/{}.jpg", "/a/day/night.jpg"); ok {
+		t.Error("expected no name for a filename that does not fit the format")
+	}
+	if name, ok := unapplyFormat("%s.jpg", "one.jpg"); !ok || name != "one" {
+		t.Errorf("expected one, got %q", name)
+	}
+}
+
+func TestFormatPlaceholders(t *testing.T) {
+	stw, err := DataToSimple("placeholders.stw", []byte("stw: 1.0\nformat: /a/%s/%s.jpg\n@00:00: night\n@08:00: day\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stw.Statics[0].Filename != "/a/night/night.jpg" {
+		t.Errorf("expected /a/night/night.jpg, got %s", stw.Statics[0].Filename)
+	}
+	s := stw.String()
+	if !strings.HasPrefix(s, "stw: 1.2") || !strings.Contains(s, "format: /a/%s/%s.jpg\n@00:00: night\n@08:00: day") || strings.Contains(s, "format:\n") {
+		t.Errorf("expected the events to be written with the format, got:\n%s", s)
+	}
+}
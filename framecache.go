@@ -0,0 +1,203 @@
+package timed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transitionSteps is how many times the wallpaper is updated during a transition
+const transitionSteps = 10
+
+// abandonedFrameAge is how old a temporary file in a frame cache must be
+// before it is removed by GC, since other processes may share the cache
+// and be in the middle of writing a frame
+const abandonedFrameAge = time.Hour
+
+// FrameCache is a cache of rendered transition frames on disk, so that the
+// frames of a transition only have to be rendered once. The frames are
+// stored in files that are named after a hash of the images, the
+// transition and how the frames are written. When the cache is larger
+// than MaxSize, the least recently used frames are removed by GC, which
+// also runs every time a new frame has been written.
+type FrameCache struct {
+	Dir     string // the directory that the frames are stored in
+	MaxSize int64  // how many bytes the frames may take, or 0 for no limit
+	Steps   int    // how many frames each transition is rendered as
+	mut     sync.Mutex
+	last    string // the frame that was most recently returned by Frame
+}
+
+// frameName matches the filenames of the frames in a frame cache
+var frameName = regexp.MustCompile(`^[0-9a-f]{32}\.[a-z]+$`)
+
+// NewFrameCache creates a frame cache in the given directory. If the
+// directory is "", $XDG_CACHE_HOME/timed/frames is used, or
+// ~/.cache/timed/frames if XDG_CACHE_HOME is not set.
+func NewFrameCache(dir string, maxSize int64) (*FrameCache, error) {
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(cacheDir, "timed", "frames")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create a directory for the frame cache: %v", err)
+	}
+	return &FrameCache{Dir: dir, MaxSize: maxSize, Steps: transitionSteps}, nil
+}
+
+// step returns which of the frames of a transition is closest to the given ratio
+func (fc *FrameCache) step(ratio float64) int {
+	return int(math.Round(math.Max(0, math.Min(1, ratio)) * float64(fc.Steps)))
+}
+
+// filename returns the filename of a frame of the given transition, where
+// the name is a hash of everything that changes the pixels of the frame.
+// The easing and the elevation of the transition only change which of the
+// frames is used, so they are not a part of the hash.
+func (fc *FrameCache) filename(fw *FatWallpaper, t *Transition, step int) (string, error) {
+	h := sha256.New()
+	for _, filename := range []string{t.FromFilename, t.ToFilename} {
+		absFilename, err := filepath.Abs(filename)
+		if err != nil {
+			return "", err
+		}
+		fi, err := os.Stat(absFilename)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n%d\n%d\n", absFilename, fi.Size(), fi.ModTime().UnixNano())
+	}
+	ff := fw.frameFormat()
+	fmt.Fprintf(h, "%s %s\n%d/%d\n%s\n%s\n", t.Type, t.ColorSpace, step, fc.Steps, ff, fw.Dither)
+	return filepath.Join(fc.Dir, hex.EncodeToString(h.Sum(nil))[:32]+ff.Ext()), nil
+}
+
+// Frame returns the filename of the cached frame of the given transition
+// that is closest to the given ratio. The frame is rendered and stored if
+// it is not in the cache, and then the cache is cleaned up with GC.
+func (fc *FrameCache) Frame(fw *FatWallpaper, t *Transition, ratio float64) (string, error) {
+	step := fc.step(ratio)
+	filename, err := fc.filename(fw, t, step)
+	if err != nil {
+		return "", err
+	}
+	fc.mut.Lock()
+	defer fc.mut.Unlock()
+	if _, err := os.Stat(filename); err == nil {
+		// Mark the frame as recently used
+		now := time.Now()
+		os.Chtimes(filename, now, now)
+		fc.last = filename
+		return filename, nil
+	}
+	// Write to a temporary file first, so that no one reads a half-written frame
+	f, err := ioutil.TempFile(fc.Dir, ".frame-*")
+	if err != nil {
+		return "", err
+	}
+	ff := fw.frameFormat()
+	if err := t.writeFrame(f, float64(step)/float64(fc.Steps), ff, fw.Dither); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	fc.last = filename
+	if err := fc.gc(); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// Prerender renders all frames of all transitions of the timed wallpaper
+// that are not already in the given frame cache
+func (fw *FatWallpaper) Prerender(fc *FrameCache) error {
+	stw, err := fw.toSimple()
+	if err != nil {
+		return err
+	}
+	for _, t := range stw.Transitions {
+		for step := 0; step <= fc.Steps; step++ {
+			if _, err := fc.Frame(stw, t, float64(step)/float64(fc.Steps)); err != nil {
+				return fmt.Errorf("could not render the transition at %s: %v", t.timestamps(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// GC removes the least recently used frames from the cache until the
+// frames take no more than MaxSize bytes. Temporary files that were left
+// behind more than an hour ago are also removed. Other files in the directory are left alone,
+// and so is the frame that was most recently returned by Frame, since it
+// may still be in use as the wallpaper.
+func (fc *FrameCache) GC() error {
+	fc.mut.Lock()
+	defer fc.mut.Unlock()
+	return fc.gc()
+}
+
+// gc removes frames from the cache, while the mutex is locked
+func (fc *FrameCache) gc() error {
+	files, err := ioutil.ReadDir(fc.Dir)
+	if err != nil {
+		return err
+	}
+	var frames []os.FileInfo
+	var size int64
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(fi.Name(), ".frame-") {
+			if time.Since(fi.ModTime()) > abandonedFrameAge {
+				// A temporary file from a frame that was being written when the program stopped
+				os.Remove(filepath.Join(fc.Dir, fi.Name()))
+			}
+			continue
+		}
+		if !frameName.MatchString(fi.Name()) {
+			continue
+		}
+		frames = append(frames, fi)
+		size += fi.Size()
+	}
+	if fc.MaxSize <= 0 || size <= fc.MaxSize {
+		return nil
+	}
+	sort.Slice(frames, func(i, j int) bool {
+		return frames[i].ModTime().Before(frames[j].ModTime())
+	})
+	for _, fi := range frames {
+		if size <= fc.MaxSize {
+			break
+		}
+		if filepath.Join(fc.Dir, fi.Name()) == fc.last {
+			continue
+		}
+		if err := os.Remove(filepath.Join(fc.Dir, fi.Name())); err != nil {
+			return err
+		}
+		size -= fi.Size()
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package timed
+
+import (
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrameCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(filename string, gray uint8) string {
+		path := filepath.Join(dir, filename)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, uniform(gray)); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	night, day := write("night.png", 0), write("day.png", 200)
+
+	stw := NewSimple("1.0", "cached", "")
+	stw.FrameFormat = &FrameFormat{Name: "png"}
+	stw.AddStatic(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), night)
+	stw.AddTransition(time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), night, day, "overlay")
+	stw.AddStatic(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), day)
+
+	fc, err := NewFrameCache(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.Prerender(fc); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(fc.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != fc.Steps+1 {
+		t.Fatalf("expected %d frames, got %d files", fc.Steps+1, len(files))
+	}
+
+	// The cached frames are used, and the ratio is rounded to the closest frame
+	tr := stw.Transitions[0]
+	first, err := fc.Frame(stw, tr, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := fc.Frame(stw, tr, 0.51); again != first {
+		t.Errorf("expected the same frame, got %s and %s", first, again)
+	}
+	if files, _ := ioutil.ReadDir(fc.Dir); len(files) != fc.Steps+1 {
+		t.Errorf("expected no new frames, got %d files", len(files))
+	}
+	if last, _ := fc.Frame(stw, tr, 1); last == first {
+		t.Error("expected another frame at the end of the transition")
+	}
+
+	// The easing only changes which frame is used, so the frames are shared
+	eased := *tr
+	eased.Easing = &Easing{Name: "ease-in"}
+	if frame, _ := fc.Frame(stw, &eased, 0.5); frame != first {
+		t.Errorf("expected the same frame for an eased transition, got %s and %s", first, frame)
+	}
+
+	// Frames of changed images are rendered again
+	write("day.png", 100)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(day, later, later)
+	changed, err := fc.Frame(stw, tr, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Error("expected a new frame when an image has changed")
+	}
+
+	// The least recently used frames are removed, but not the frame that
+	// was returned last, not other files in the same directory, and not
+	// temporary files that may still be written to by other processes
+	for _, filename := range []string{"timed-0.jpg", ".timed-123", ".frame-new", ".frame-old"} {
+		if err := ioutil.WriteFile(filepath.Join(fc.Dir, filename), []byte("frame"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	earlier := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(fc.Dir, ".frame-old"), earlier, earlier)
+	os.Chtimes(first, later, later)
+	fi, err := os.Stat(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.MaxSize = fi.Size()
+	if err := fc.GC(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{".frame-new", ".timed-123", filepath.Base(changed), "timed-0.jpg"}
+	expectFiles := func(expected []string) {
+		t.Helper()
+		files, _ := ioutil.ReadDir(fc.Dir)
+		var names []string
+		for _, fi := range files {
+			names = append(names, fi.Name())
+		}
+		sort.Strings(names)
+		if strings.Join(names, " ") != strings.Join(expected, " ") {
+			t.Errorf("expected the files %v, got %v", expected, names)
+		}
+	}
+	expectFiles(expected)
+
+	// The cache is cleaned up when a new frame is written
+	end, err := fc.Frame(stw, tr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFiles([]string{".frame-new", ".timed-123", filepath.Base(end), "timed-0.jpg"})
+}
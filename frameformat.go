@@ -0,0 +1,117 @@
+package timed
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/anthonynsimon/bild/imgio"
+)
+
+// FrameFormat is the image format that blended frames are written in
+type FrameFormat struct {
+	Name        string               // "jpeg", "png" or "bmp"
+	Quality     int                  // the JPEG quality, from 1 to 100
+	Compression png.CompressionLevel // the PNG compression level
+}
+
+// DefaultFrameFormat is used for writing blended frames when the timed
+// wallpaper has no frame format
+var DefaultFrameFormat = &FrameFormat{Name: "jpeg", Quality: 100}
+
+// pngCompressionLevels are the names of the PNG compression levels
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// ParseFrameFormat parses a frame format, like "jpeg", "jpeg:90", "png",
+// "png:fast" or "bmp". The JPEG quality is 100 by default, and the PNG
+// compression level may be "default", "none", "fast" or "best".
+func ParseFrameFormat(s string) (*FrameFormat, error) {
+	name, option := s, ""
+	if i := strings.Index(s, ":"); i != -1 {
+		name, option = s[:i], s[i+1:]
+	}
+	switch strings.ToLower(name) {
+	case "jpeg", "jpg":
+		ff := &FrameFormat{Name: "jpeg", Quality: 100}
+		if option != "" {
+			quality, err := strconv.Atoi(option)
+			if err != nil || quality < 1 || quality > 100 {
+				return nil, fmt.Errorf("the JPEG quality must be from 1 to 100: %s", option)
+			}
+			ff.Quality = quality
+		}
+		return ff, nil
+	case "png":
+		ff := &FrameFormat{Name: "png"}
+		if option != "" {
+			level, ok := pngCompressionLevels[option]
+			if !ok {
+				return nil, fmt.Errorf("unknown PNG compression level: %s", option)
+			}
+			ff.Compression = level
+		}
+		return ff, nil
+	case "bmp":
+		if option != "" {
+			return nil, fmt.Errorf("BMP has no options: %s", option)
+		}
+		return &FrameFormat{Name: "bmp"}, nil
+	}
+	return nil, fmt.Errorf("unknown frame format: %s", s)
+}
+
+// String returns the frame format, as it is given to ParseFrameFormat
+func (ff *FrameFormat) String() string {
+	switch ff.Name {
+	case "jpeg":
+		return "jpeg:" + strconv.Itoa(ff.Quality)
+	case "png":
+		for name, level := range pngCompressionLevels {
+			if level == ff.Compression && ff.Compression != png.DefaultCompression {
+				return "png:" + name
+			}
+		}
+	}
+	return ff.Name
+}
+
+// Ext returns the filename extension for the frame format
+func (ff *FrameFormat) Ext() string {
+	switch ff.Name {
+	case "png":
+		return ".png"
+	case "bmp":
+		return ".bmp"
+	}
+	return ".jpg"
+}
+
+// Encoder returns an encoder for the frame format
+func (ff *FrameFormat) Encoder() imgio.Encoder {
+	switch ff.Name {
+	case "png":
+		encoder := &png.Encoder{CompressionLevel: ff.Compression}
+		return func(w io.Writer, img image.Image) error {
+			return encoder.Encode(w, img)
+		}
+	case "bmp":
+		return imgio.BMPEncoder()
+	}
+	return imgio.JPEGEncoder(ff.Quality)
+}
+
+// frameFormat returns the frame format of the timed wallpaper, or the default one
+func (fw *FatWallpaper) frameFormat() *FrameFormat {
+	if fw.FrameFormat != nil {
+		return fw.FrameFormat
+	}
+	return DefaultFrameFormat
+}
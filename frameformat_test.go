@@ -0,0 +1,56 @@
+package timed
+
+import (
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrameFormat(t *testing.T) {
+	for s, expected := range map[string]string{"jpeg": "jpeg:100", "jpg:85": "jpeg:85", "png": "png", "png:fast": "png:fast", "bmp": "bmp"} {
+		ff, err := ParseFrameFormat(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ff.String() != expected {
+			t.Errorf("expected %s for %s, got %s", expected, s, ff)
+		}
+	}
+	for _, s := range []string{"gif", "jpeg:0", "jpeg:high", "png:fastest", "bmp:1"} {
+		if _, err := ParseFrameFormat(s); err == nil {
+			t.Errorf("expected an error for %s", s)
+		}
+	}
+
+	// The frames are written in the frame format, with a matching extension
+	dir, err := ioutil.TempDir("", "timed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_RUNTIME_DIR", os.Getenv("XDG_RUNTIME_DIR"))
+	os.Setenv("XDG_RUNTIME_DIR", dir)
+	frames, err := NewFrameWriter("frame.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stw := NewSimple("1.0", "frames", "")
+	stw.FrameFormat, _ = ParseFrameFormat("png:best")
+	filename, err := frames.Write(Crossfade(uniform(0), uniform(255), 0.5, LinearLight), stw.frameFormat(), stw.Dither)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(filename) != ".png" {
+		t.Errorf("expected the extension to follow the frame format, got %s", filename)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := png.Decode(f); err != nil {
+		t.Errorf("expected a PNG file: %s", err)
+	}
+}
@@ -0,0 +1,138 @@
+package timed
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FrameWriter writes blended frames to files, where every frame is first
+// written to a temporary file and then renamed into place, so that a
+// desktop never reads a half-written frame. The frames alternate between
+// two filenames, since some desktops will not reload a wallpaper with the
+// same filename as before.
+type FrameWriter struct {
+	Dir     string // the directory that the frames are written to
+	Prefix  string // the start of the filenames, like "timed"
+	mut     sync.Mutex
+	count   int
+	written map[string]bool
+	ownDir  bool // if the directory was created for this frame writer, and is removed by Cleanup
+}
+
+// frameDir returns the directory where blended frames are written by
+// default: $XDG_RUNTIME_DIR/timed, or the cache directory of the user,
+// or the directory for temporary files
+func frameDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "timed")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "timed")
+	}
+	return filepath.Join(os.TempDir(), "timed")
+}
+
+// framePrefix returns the start of the filenames of frames that are named
+// after the given filename, which is the name without the directory and
+// the extension, or "timed" if the filename is ""
+func framePrefix(filename string) string {
+	if filename == "" {
+		return "timed"
+	}
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+}
+
+// NewFrameWriter creates a FrameWriter that writes the frames to a new
+// directory within $XDG_RUNTIME_DIR/timed, or within the cache directory of
+// the user if XDG_RUNTIME_DIR is not set, so that several frame writers do
+// not overwrite the frames of each other. Only the name of the given
+// filename, without the directory and the extension, is used, as the start
+// of the filenames of the frames. If the filename is "", "timed" is used.
+// The directory is removed by Cleanup.
+func NewFrameWriter(filename string) (*FrameWriter, error) {
+	if err := os.MkdirAll(frameDir(), 0700); err != nil {
+		return nil, fmt.Errorf("could not create a directory for the frames: %v", err)
+	}
+	dir, err := ioutil.TempDir(frameDir(), "timed-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create a directory for the frames: %v", err)
+	}
+	return &FrameWriter{Dir: dir, Prefix: framePrefix(filename), written: make(map[string]bool), ownDir: true}, nil
+}
+
+// frameWriterAt creates a FrameWriter that writes the frames next to the
+// given filename, like "frame-0.jpg" and "frame-1.jpg" for "frame.jpg", or
+// to $XDG_RUNTIME_DIR/timed if the filename has no directory
+func frameWriterAt(filename string) (*FrameWriter, error) {
+	dir := frameDir()
+	if strings.ContainsRune(filename, filepath.Separator) {
+		dir = filepath.Dir(filename)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create a directory for the frames: %v", err)
+	}
+	return &FrameWriter{Dir: dir, Prefix: framePrefix(filename), written: make(map[string]bool)}, nil
+}
+
+// Write writes a frame in the given frame format, dithered with the given
+// dithering method, and returns the filename of the frame
+func (w *FrameWriter) Write(img image.Image, ff *FrameFormat, dither string) (string, error) {
+	return w.WriteFunc(ff.Ext(), func(f io.Writer) error {
+		return ff.Encoder()(f, Dither(img, dither))
+	})
+}
+
+// WriteFunc writes a frame with the given filename extension, like ".jpg",
+// where the frame is encoded by the given function, and returns the
+// filename of the frame
+func (w *FrameWriter) WriteFunc(ext string, encode func(io.Writer) error) (string, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	filename := filepath.Join(w.Dir, fmt.Sprintf("%s-%d%s", w.Prefix, w.count%2, ext))
+	f, err := ioutil.TempFile(w.Dir, "."+w.Prefix+"-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if err := encode(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	w.count++
+	w.written[filename] = true
+	return filename, nil
+}
+
+// Cleanup removes the frames that have been written, and the directory of
+// the frames, if it was created by NewFrameWriter
+func (w *FrameWriter) Cleanup() error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	var lastErr error
+	for filename := range w.written {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			lastErr = err
+		}
+		delete(w.written, filename)
+	}
+	if w.ownDir {
+		if err := os.RemoveAll(w.Dir); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
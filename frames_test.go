@@ -0,0 +1,94 @@
+package timed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrameWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_RUNTIME_DIR", os.Getenv("XDG_RUNTIME_DIR"))
+	os.Setenv("XDG_RUNTIME_DIR", dir)
+
+	// Only the name of the given filename is used, and the frames are
+	// written to a new directory within $XDG_RUNTIME_DIR/timed
+	frames, err := NewFrameWriter("/usr/share/backgrounds/frame.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(frames.Dir) != filepath.Join(dir, "timed") {
+		t.Errorf("expected the frames to be written within %s, got %s", filepath.Join(dir, "timed"), frames.Dir)
+	}
+	var filenames []string
+	for i := 0; i < 3; i++ {
+		filename, err := frames.Write(uniform(uint8(i*100)), DefaultFrameFormat, DitherNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	// The frames alternate between two filenames
+	expected := []string{"frame-0.jpg", "frame-1.jpg", "frame-0.jpg"}
+	for i, filename := range filenames {
+		if filename != filepath.Join(frames.Dir, expected[i]) {
+			t.Errorf("expected %s, got %s", expected[i], filename)
+		}
+	}
+
+	// Only the frames are left, and not the temporary files they were written to
+	files, err := ioutil.ReadDir(frames.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected two frames, got %d files", len(files))
+	}
+
+	// Another frame writer does not overwrite the frames
+	other, err := NewFrameWriter("/usr/share/backgrounds/frame.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Dir == frames.Dir {
+		t.Errorf("expected two frame writers to use different directories, got %s", other.Dir)
+	}
+	if err := other.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := frames.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(frames.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected the frames and their directory to be removed, got %v", err)
+	}
+
+	// Without a filename, the frames are named after "timed"
+	frames, err = NewFrameWriter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer frames.Cleanup()
+	if frames.Prefix != "timed" {
+		t.Errorf("expected the frames to be named after timed, got %s", frames.Prefix)
+	}
+
+	// The frames of the initial wallpaper are written next to the given filename
+	frames, err = frameWriterAt(filepath.Join(dir, "initial", "frame.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames.Dir != filepath.Join(dir, "initial") || frames.Prefix != "frame" {
+		t.Errorf("expected the frame frames in %s, got %s frames in %s", filepath.Join(dir, "initial"), frames.Prefix, frames.Dir)
+	}
+	if frames, err = frameWriterAt("frame.png"); err != nil || frames.Dir != filepath.Join(dir, "timed") {
+		t.Errorf("expected the frames to be written to %s, got %v", filepath.Join(dir, "timed"), err)
+	}
+}
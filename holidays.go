@@ -0,0 +1,202 @@
+package timed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DateRange is a range of dates, from From up to and including UpTo. Only
+// the dates of the timestamps are used. Yearly date ranges happen every
+// year, starting with the year of From.
+type DateRange struct {
+	From   time.Time
+	UpTo   time.Time
+	Yearly bool
+}
+
+// dateLayout is how dates are written in Simple Timed Wallpaper files
+const dateLayout = "2006-01-02"
+
+// ParseDateRange parses a date, like "2026-12-24", or a range of dates,
+// like "2026-12-20..2027-01-02"
+func ParseDateRange(s string) (*DateRange, error) {
+	fields := strings.SplitN(s, "..", 2)
+	from, err := time.Parse(dateLayout, strings.TrimSpace(fields[0]))
+	if err != nil {
+		return nil, fmt.Errorf("not a date: %s", fields[0])
+	}
+	if len(fields) == 1 {
+		return &DateRange{From: from, UpTo: from}, nil
+	}
+	upTo, err := time.Parse(dateLayout, strings.TrimSpace(fields[1]))
+	if err != nil {
+		return nil, fmt.Errorf("not a date: %s", fields[1])
+	}
+	if upTo.Before(from) {
+		return nil, fmt.Errorf("the date range ends before it starts: %s", s)
+	}
+	return &DateRange{From: from, UpTo: upTo}, nil
+}
+
+// String returns the date range as it is written in Simple Timed Wallpaper files
+func (dr *DateRange) String() string {
+	if dr.UpTo.Equal(dr.From) {
+		return dr.From.Format(dateLayout)
+	}
+	return dr.From.Format(dateLayout) + ".." + dr.UpTo.Format(dateLayout)
+}
+
+// days returns how many days the date range lasts, within a year
+func (dr *DateRange) days() int {
+	return int(dr.UpTo.Sub(dr.From)/h24) + 1
+}
+
+// dayNumber returns a number for the date of the given time, that is
+// larger for later dates
+func dayNumber(t time.Time) int {
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
+
+// Contains checks if the date of the given time is within the date range
+func (dr *DateRange) Contains(date time.Time) bool {
+	day := dayNumber(date)
+	from := dayNumber(dr.From)
+	upTo := dayNumber(dr.UpTo)
+	if !dr.Yearly {
+		return from <= day && day <= upTo
+	}
+	if day < from {
+		return false
+	}
+	// Compare only the months and days
+	day, from, upTo = day%10000, from%10000, upTo%10000
+	if dr.From.Year() == dr.UpTo.Year() {
+		return from <= day && day <= upTo
+	}
+	// The range lasts past the end of the year
+	return day >= from || day <= upTo
+}
+
+// LoadHolidays reads a list of holidays from a file. The file is either an
+// iCalendar file, if the filename ends with ".ics", or a list of dates or
+// date ranges, one per line, where the rest of each line is ignored.
+func LoadHolidays(filename string) ([]*DateRange, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(strings.ToLower(filename), ".ics") {
+		return parseICS(data)
+	}
+	var holidays []*DateRange
+	for lineCount, byteLine := range bytes.Split(data, []byte("\n")) {
+		trimmed := strings.TrimSpace(string(byteLine))
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		dr, err := ParseDateRange(strings.Fields(trimmed)[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s, line %d: %s", filename, lineCount, err)
+		}
+		holidays = append(holidays, dr)
+	}
+	return holidays, nil
+}
+
+// icsDate parses a DATE or DATE-TIME value from an iCalendar file, where
+// only the date is used
+func icsDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("not a date: %s", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+// parseICS finds the dates of all events in an iCalendar file. Events that
+// repeat every year, with "RRULE:FREQ=YEARLY", are yearly date ranges.
+func parseICS(data []byte) ([]*DateRange, error) {
+	// Long lines are folded by starting the next line with a space or a tab
+	text := strings.Replace(string(data), "\r\n", "\n", -1)
+	text = strings.Replace(text, "\n ", "", -1)
+	text = strings.Replace(text, "\n\t", "", -1)
+
+	var (
+		holidays []*DateRange
+		current  *DateRange
+		hasEnd   bool
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		// Properties may have parameters after a semicolon, like "DTSTART;VALUE=DATE"
+		name := strings.ToUpper(strings.SplitN(fields[0], ";", 2)[0])
+		value := strings.TrimSpace(fields[1])
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &DateRange{}
+			hasEnd = false
+		case current == nil:
+		case name == "DTSTART":
+			from, err := icsDate(value)
+			if err != nil {
+				return nil, err
+			}
+			current.From = from
+		case name == "DTEND":
+			// The end date is not a part of the event
+			upTo, err := icsDate(value)
+			if err != nil {
+				return nil, err
+			}
+			current.UpTo = upTo.AddDate(0, 0, -1)
+			hasEnd = true
+		case name == "RRULE":
+			current.Yearly = strings.Contains(strings.ToUpper(value), "FREQ=YEARLY")
+		case name == "END" && value == "VEVENT":
+			if current.From.IsZero() {
+				return nil, errors.New("iCalendar event without a start date")
+			}
+			if !hasEnd || current.UpTo.Before(current.From) {
+				current.UpTo = current.From
+			}
+			holidays = append(holidays, current)
+			current = nil
+		}
+	}
+	return holidays, nil
+}
+
+// SetHolidays reads the holidays from the given file, which is used for
+// events that only apply to holidays. A relative filename is relative to the
+// directory of the timed wallpaper.
+func (fw *FatWallpaper) SetHolidays(filename string) error {
+	path := filename
+	if !filepath.IsAbs(path) && fw.Path != "" {
+		path = filepath.Join(filepath.Dir(fw.Path), path)
+	}
+	holidays, err := LoadHolidays(path)
+	if err != nil {
+		return err
+	}
+	fw.HolidaysFile = filename
+	fw.Holidays = holidays
+	return nil
+}
+
+// isHoliday checks if the given date is one of the given holidays
+func isHoliday(date time.Time, holidays []*DateRange) bool {
+	for _, dr := range holidays {
+		if dr.Contains(date) {
+			return true
+		}
+	}
+	return false
+}
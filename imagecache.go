@@ -0,0 +1,126 @@
+package timed
+
+import (
+	"container/list"
+	"image"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anthonynsimon/bild/imgio"
+)
+
+// ImageCache is a cache of decoded images, where the least recently used
+// images are removed when the images take more memory than the budget.
+// An image is decoded again if the size or the modification time of the
+// file has changed. The cached images are shared, and must not be modified.
+type ImageCache struct {
+	Budget  int64 // how many bytes the decoded images may take
+	mut     sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // the most recently used images first
+	used    int64
+}
+
+// cachedImage is a decoded image in an ImageCache
+type cachedImage struct {
+	path    string
+	size    int64
+	modTime time.Time
+	img     image.Image
+	bytes   int64
+}
+
+// DefaultImageCache is the image cache that is used by the event loop and
+// when rendering transitions, with a budget of 512 MiB
+var DefaultImageCache = NewImageCache(512 << 20)
+
+// NewImageCache creates an image cache with the given budget, in bytes
+func NewImageCache(budget int64) *ImageCache {
+	return &ImageCache{Budget: budget, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// imageBytes returns about how many bytes the pixels of the image take
+func imageBytes(img image.Image) int64 {
+	switch v := img.(type) {
+	case *image.RGBA:
+		return int64(len(v.Pix))
+	case *image.NRGBA:
+		return int64(len(v.Pix))
+	case *image.RGBA64:
+		return int64(len(v.Pix))
+	case *image.Gray:
+		return int64(len(v.Pix))
+	case *image.YCbCr:
+		return int64(len(v.Y) + len(v.Cb) + len(v.Cr))
+	}
+	b := img.Bounds()
+	return int64(b.Dx()) * int64(b.Dy()) * 4
+}
+
+// Open returns the decoded image for the given filename, from the cache if
+// the file has not changed since it was decoded
+func (c *ImageCache) Open(filename string) (image.Image, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	c.mut.Lock()
+	if e, ok := c.entries[filename]; ok {
+		ci := e.Value.(*cachedImage)
+		if ci.size == fi.Size() && ci.modTime.Equal(fi.ModTime()) {
+			c.order.MoveToFront(e)
+			c.mut.Unlock()
+			return ci.img, nil
+		}
+		// The file has changed
+		c.remove(e)
+	}
+	c.mut.Unlock()
+
+	img, err := imgio.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	ci := &cachedImage{path: filename, size: fi.Size(), modTime: fi.ModTime(), img: img, bytes: imageBytes(img)}
+	if ci.bytes > c.Budget {
+		// Too large to be cached
+		return img, nil
+	}
+	if e, ok := c.entries[filename]; ok {
+		// Decoded by someone else in the meantime
+		c.remove(e)
+	}
+	c.entries[filename] = c.order.PushFront(ci)
+	c.used += ci.bytes
+	for c.used > c.Budget {
+		c.remove(c.order.Back())
+	}
+	return img, nil
+}
+
+// remove removes an image from the cache, while the mutex is locked
+func (c *ImageCache) remove(e *list.Element) {
+	ci := c.order.Remove(e).(*cachedImage)
+	delete(c.entries, ci.path)
+	c.used -= ci.bytes
+}
+
+// Len returns how many images are in the cache
+func (c *ImageCache) Len() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.order.Len()
+}
+
+// Clear removes all images from the cache
+func (c *ImageCache) Clear() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.used = 0
+}
@@ -0,0 +1,72 @@
+package timed
+
+import (
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImageCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(filename string, gray uint8) string {
+		path := filepath.Join(dir, filename)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, uniform(gray)); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a, b, c := write("a.png", 10), write("b.png", 20), write("c.png", 30)
+
+	// Each 4x4 image takes 64 bytes, so only two of them fit
+	cache := NewImageCache(128)
+	first, err := cache.Open(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.Open(a); again != first {
+		t.Error("expected the cached image")
+	}
+	cache.Open(b)
+	cache.Open(a)
+	cache.Open(c)
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 cached images, got %d", cache.Len())
+	}
+	if again, _ := cache.Open(a); again != first {
+		t.Error("expected the most recently used image to be kept")
+	}
+
+	// Changed files are decoded again
+	write("a.png", 40)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(a, later, later)
+	changed, err := cache.Open(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := changed.At(0, 0).RGBA(); changed == first || r>>8 != 40 {
+		t.Error("expected the changed file to be decoded again")
+	}
+
+	// Images that are larger than the budget are not cached
+	cache = NewImageCache(32)
+	cache.Open(a)
+	if cache.Len() != 0 {
+		t.Errorf("expected no cached images, got %d", cache.Len())
+	}
+	if _, err := cache.Open(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,197 @@
+package timed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Include is an "include" field in a Simple Timed Wallpaper file, which
+// splices the events of another Simple Timed Wallpaper file into this one.
+// If a window is given, only the events that start within the window are
+// included. The included events are then moved by the offset.
+type Include struct {
+	Path      string // as written in the file, relative to the including file
+	Offset    time.Duration
+	HasWindow bool
+	From      time.Time
+	UpTo      time.Time
+}
+
+// inheritedFields are the header fields that a Simple Timed Wallpaper has
+// taken from the files that it includes
+type inheritedFields struct {
+	location    *time.Location
+	coordinates bool
+	latitude    float64
+	longitude   float64
+}
+
+// ParseInclude parses the value of an "include" field, which is a path,
+// optionally followed by a time window like "06:00-18:00" and an offset
+// like "+2h" or "-30m"
+func ParseInclude(s string) (*Include, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing path in include: %s", s)
+	}
+	inc := &Include{Path: fields[0]}
+	for _, field := range fields[1:] {
+		switch {
+		case strings.HasPrefix(field, "+") || strings.HasPrefix(field, "-"):
+			offset, err := time.ParseDuration(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid offset in include: %s", field)
+			}
+			inc.Offset = offset
+		case strings.Contains(field, "-"):
+			times := strings.SplitN(field, "-", 2)
+			from, err1 := parseClock(times[0])
+			upTo, err2 := parseClock(times[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid time window in include: %s", field)
+			}
+			inc.HasWindow, inc.From, inc.UpTo = true, from, upTo
+		default:
+			return nil, fmt.Errorf("invalid include: %s", s)
+		}
+	}
+	return inc, nil
+}
+
+// String returns the include as it is written after "include:" in Simple
+// Timed Wallpaper files
+func (inc *Include) String() string {
+	s := inc.Path
+	if inc.HasWindow {
+		s += " " + cFmt(inc.From) + "-" + cFmt(inc.UpTo)
+	}
+	if inc.Offset > 0 {
+		s += " +" + dFmt(inc.Offset)
+	} else if inc.Offset < 0 {
+		s += " -" + dFmt(-inc.Offset)
+	}
+	return s
+}
+
+// within checks if the given time of day is within the window of the include
+func (inc *Include) within(t time.Time) bool {
+	if !inc.HasWindow {
+		return true
+	}
+	from := sinceMidnight(inc.From)
+	return mod24(sinceMidnight(t)-from) < mod24(sinceMidnight(inc.UpTo)-from)
+}
+
+// withinCron checks if any of the times of day where the given cron
+// expression may apply are within the window of the include
+func (inc *Include) withinCron(spec *CronSpec) bool {
+	if !inc.HasWindow {
+		return true
+	}
+	for hour := 0; hour < 24; hour++ {
+		for minute := 0; minute < 60; minute++ {
+			t := clockTime(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+			if spec.hours&(1<<uint(hour)) != 0 && spec.minutes&(1<<uint(minute)) != 0 && spec.inWindows(t) && inc.within(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// restricted returns a copy of the given cron expression, where only the
+// times within the window of the include are used
+func (inc *Include) restricted(spec *CronSpec) *CronSpec {
+	if !inc.HasWindow {
+		return spec
+	}
+	restricted := *spec
+	restricted.windows = append(append([]*Include{}, spec.windows...), inc)
+	return &restricted
+}
+
+// shifted returns a solar time that is moved by the offset of the include
+func (inc *Include) shifted(st *SolarTime) *SolarTime {
+	if st == nil {
+		return nil
+	}
+	return &SolarTime{Event: st.Event, Offset: st.Offset + inc.Offset}
+}
+
+// include reads the Simple Timed Wallpaper file of the given include, and
+// adds the events that are within the window of the include to this timed
+// wallpaper, moved by the offset of the include. The chain is the absolute
+// paths of the files that are being included, for detecting include cycles.
+func (fw *FatWallpaper) include(inc *Include, chain []string) error {
+	path := inc.Path
+	if !filepath.IsAbs(path) && fw.Path != "" {
+		path = filepath.Join(filepath.Dir(fw.Path), path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for _, included := range chain {
+		if included == absPath {
+			return fmt.Errorf("include cycle: %s", strings.Join(append(chain, absPath), " -> "))
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	other, err := dataToSimple(path, data, append(chain, absPath))
+	if err != nil {
+		return err
+	}
+
+	for _, s := range other.Statics {
+		if s.Cron != nil {
+			// The times of cron expressions are checked, instead of the time of the static image
+			if !inc.withinCron(s.Cron) {
+				continue
+			}
+			if inc.Offset != 0 {
+				return fmt.Errorf("can not move events that are shown according to cron expressions, in %s", path)
+			}
+			s.Cron = inc.restricted(s.Cron)
+		} else if !inc.within(s.At) {
+			continue
+		}
+		s.At = clockTime(sinceMidnight(s.At) + inc.Offset)
+		s.Solar = inc.shifted(s.Solar)
+		s.Source = inc.Path
+		fw.Statics = append(fw.Statics, s)
+	}
+	for _, t := range other.Transitions {
+		if !inc.within(t.From) {
+			continue
+		}
+		t.From = clockTime(sinceMidnight(t.From) + inc.Offset)
+		t.UpTo = clockTime(sinceMidnight(t.UpTo) + inc.Offset)
+		t.FromSolar = inc.shifted(t.FromSolar)
+		t.UpToSolar = inc.shifted(t.UpToSolar)
+		t.Source = inc.Path
+		fw.Transitions = append(fw.Transitions, t)
+	}
+
+	// Use the fields of the included file that are not given in this file,
+	// and remember them, so that they are not written to this file
+	if fw.Location == nil && other.Location != nil {
+		fw.Location = other.Location
+		fw.inherited.location = other.Location
+	}
+	if fw.Latitude == 0 && fw.Longitude == 0 && (other.Latitude != 0 || other.Longitude != 0) {
+		fw.Latitude, fw.Longitude = other.Latitude, other.Longitude
+		fw.inherited.coordinates = true
+		fw.inherited.latitude, fw.inherited.longitude = other.Latitude, other.Longitude
+	}
+	if fw.Holidays == nil {
+		fw.Holidays = other.Holidays
+	}
+	fw.Includes = append(fw.Includes, inc)
+	return nil
+}
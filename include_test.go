@@ -0,0 +1,102 @@
+package timed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(filename, contents string) string {
+		path := filepath.Join(dir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("base/day.stw", "stw: 1.0\nformat: /usr/share/backgrounds/%s.jpg\n@06:00-08:00: night .. day\n@08:00: day\n@18:00-20:00: day .. night\n@20:00: night\n")
+
+	// Only the morning, moved one hour later
+	path := write("variant.stw", "stw: 1.0\nname: variant\ninclude: base/day.stw 00:00-12:00 +1h\n@12:00: noon\n")
+	stw, err := ParseSTW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.Validate(); err != nil {
+		t.Error(err)
+	}
+	if len(stw.Statics) != 2 || len(stw.Transitions) != 1 {
+		t.Fatalf("expected 2 static images and 1 transition, got %d and %d", len(stw.Statics), len(stw.Transitions))
+	}
+	if from, _, _, _ := stw.Shown(clockTime(9 * time.Hour)); from != "/usr/share/backgrounds/day.jpg" {
+		t.Errorf("expected the included day image at 09:00, got %s", from)
+	}
+	if from, to, _, _ := stw.Shown(clockTime(7*time.Hour + 30*time.Minute)); from != "/usr/share/backgrounds/night.jpg" || to != "/usr/share/backgrounds/day.jpg" {
+		t.Errorf("expected the included transition to be moved to 07:00-09:00, got %s .. %s", from, to)
+	}
+	// The include field is written instead of the included events
+	if s := stw.String(); !strings.Contains(s, "include: base/day.stw 00:00-12:00 +1h") || strings.Contains(s, "day.jpg") {
+		t.Errorf("expected the include field to be written, got:\n%s", s)
+	}
+
+	// Include cycles are errors that mention the include chain
+	write("a.stw", "stw: 1.0\ninclude: b.stw\n@00:00: a\n")
+	write("b.stw", "stw: 1.0\ninclude: a.stw\n@12:00: b\n")
+	_, err = ParseSTW(filepath.Join(dir, "a.stw"))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") || !strings.Contains(err.Error(), "included from") {
+		t.Errorf("expected an include cycle error, got %v", err)
+	}
+	write("c.stw", "stw: 1.0\ninclude: missing.stw\n@00:00: c\n")
+	if _, err := ParseSTW(filepath.Join(dir, "c.stw")); err == nil || !strings.Contains(err.Error(), "included from") {
+		t.Errorf("expected an error that mentions the including file, got %v", err)
+	}
+
+	// Cron images are included if they are shown within the window, and only at those times
+	write("slides.stw", "stw: 1.2\ntz: Europe/Oslo\nlatitude: 59.91\nlongitude: 10.75\n@00:00: night\n@cron 0 * * * *: slide\n@cron 0 22 * * *: late\n")
+	path = write("office.stw", "stw: 1.2\nname: office\ninclude: slides.stw 08:00-16:00\n@sunset: evening\n")
+	stw, err = ParseSTW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stw.Statics) != 2 {
+		t.Fatalf("expected the evening image and the slides, got %d static images", len(stw.Statics))
+	}
+	onDate, err := stw.OnDate(time.Date(2026, 10, 16, 0, 0, 0, 0, stw.location()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slides := 0
+	for _, s := range onDate.Statics {
+		if s.Filename == "slide" {
+			slides++
+			if h := s.At.Hour(); h < 8 || h >= 16 {
+				t.Errorf("expected only slides within the window, got one at %s", cFmt(s.At))
+			}
+		}
+	}
+	if slides != 8 {
+		t.Errorf("expected 8 slides, got %d", slides)
+	}
+	// The time zone and position of the included file are used, but not written
+	if stw.location().String() != "Europe/Oslo" || stw.Latitude != 59.91 {
+		t.Errorf("expected the time zone and position of the included file, got %s and %v", stw.location(), stw.Latitude)
+	}
+	s := stw.String()
+	if strings.Contains(s, "tz:") || strings.Contains(s, "latitude:") {
+		t.Errorf("expected the fields of the included file to not be written, got:\n%s", s)
+	}
+	if reparsed, err := DataToSimple(path, []byte(s)); err != nil || reparsed.String() != s {
+		t.Errorf("expected the same timed wallpaper when writing and parsing, got:\n%s", reparsed)
+	}
+}
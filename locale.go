@@ -0,0 +1,119 @@
+package timed
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// parseNameKey checks if the given field is a localized name, like
+// "name[nb]", and returns the locale
+func parseNameKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, "name[") || !strings.HasSuffix(key, "]") {
+		return "", false
+	}
+	locale := key[len("name[") : len(key)-1]
+	return locale, locale != ""
+}
+
+// SetNameFor sets the name of this timed wallpaper for the given locale,
+// like "nb" or "pt_BR". An empty name removes the localized name.
+func (fw *FatWallpaper) SetNameFor(locale, name string) {
+	if name == "" {
+		delete(fw.Names, locale)
+		return
+	}
+	if fw.Names == nil {
+		fw.Names = make(map[string]string)
+	}
+	fw.Names[locale] = name
+}
+
+// localeFallbacks returns the locales that are tried for the given locale,
+// from the most to the least specific. For example, "nb_NO.UTF-8@euro"
+// gives "nb_NO.UTF-8@euro", "nb_NO@euro", "nb_NO" and "nb".
+func localeFallbacks(locale string) []string {
+	fallbacks := []string{locale}
+	add := func(s string) {
+		if s != "" && s != fallbacks[len(fallbacks)-1] {
+			fallbacks = append(fallbacks, s)
+		}
+	}
+	modifier := ""
+	if i := strings.Index(locale, "@"); i != -1 {
+		locale, modifier = locale[:i], locale[i:]
+	}
+	if i := strings.Index(locale, "."); i != -1 {
+		locale = locale[:i]
+	}
+	add(locale + modifier)
+	add(locale)
+	if i := strings.Index(locale, "_"); i != -1 {
+		add(locale[:i])
+	}
+	return fallbacks
+}
+
+// environmentLocales returns the preferred locales, as given by the
+// environment variables, following the same rules as gettext. The locale
+// is given by LC_ALL, LC_MESSAGES or LANG, and LANGUAGE may give a colon
+// separated list of locales that are preferred, unless the locale is "C".
+func environmentLocales() []string {
+	locale := ""
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = os.Getenv(name); locale != "" {
+			break
+		}
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+	var locales []string
+	for _, language := range strings.Split(os.Getenv("LANGUAGE"), ":") {
+		if language != "" {
+			locales = append(locales, language)
+		}
+	}
+	return append(locales, locale)
+}
+
+// NameFor returns the name of this timed wallpaper for the given locale,
+// like "nb_NO.UTF-8". If there is no name for the locale, less specific
+// locales are tried, like "nb_NO" and "nb", before falling back to the name.
+// If the given locale is "", the locales from the LANGUAGE, LC_ALL,
+// LC_MESSAGES and LANG environment variables are used.
+func (fw *FatWallpaper) NameFor(locale string) string {
+	locales := []string{locale}
+	if locale == "" {
+		locales = environmentLocales()
+	}
+	for _, locale := range locales {
+		for _, fallback := range localeFallbacks(locale) {
+			if name, ok := fw.Names[fallback]; ok {
+				return name
+			}
+		}
+	}
+	return fw.Name
+}
+
+// locales returns the locales that this timed wallpaper has names for, sorted
+func (fw *FatWallpaper) locales() []string {
+	var locales []string
+	for locale := range fw.Names {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// nameLines returns the localized names as they are written in Simple
+// Timed Wallpaper files, sorted by locale
+func (fw *FatWallpaper) nameLines() []string {
+	var lines []string
+	for _, locale := range fw.locales() {
+		lines = append(lines, fmt.Sprintf("name[%s]: %s", locale, fw.Names[locale]))
+	}
+	return lines
+}
@@ -0,0 +1,68 @@
+package timed
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNameFor(t *testing.T) {
+	data := []byte("stw: 1.0\nname: Dunes\nname[nb]: Sanddyner\nname[pt_BR]: Dunas\nformat: %s.jpg\n@00:00: night\n")
+	stw, err := DataToSimple("dunes.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for locale, expected := range map[string]string{
+		"nb":               "Sanddyner",
+		"nb_NO.UTF-8":      "Sanddyner",
+		"pt_BR.UTF-8@euro": "Dunas",
+		"pt_PT":            "Dunes",
+		"de_DE":            "Dunes",
+	} {
+		if name := stw.NameFor(locale); name != expected {
+			t.Errorf("expected %s for %s, got %s", expected, locale, name)
+		}
+	}
+	if s := stw.String(); !strings.Contains(s, "name: Dunes\nname[nb]: Sanddyner\nname[pt_BR]: Dunas\n") {
+		t.Errorf("expected the localized names to be written, got:\n%s", s)
+	}
+
+	// LANGUAGE is a list of preferred locales, unless the locale is C
+	for _, name := range []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+	os.Setenv("LANG", "de_DE.UTF-8")
+	os.Setenv("LANGUAGE", "sv:nb")
+	if name := stw.NameFor(""); name != "Sanddyner" {
+		t.Errorf("expected the name for nb from LANGUAGE, got %s", name)
+	}
+	os.Setenv("LC_ALL", "C")
+	if name := stw.NameFor(""); name != "Dunes" {
+		t.Errorf("expected LANGUAGE to be ignored for the C locale, got %s", name)
+	}
+
+	// The localized names are read from and written to GNOME wallpaper catalogs
+	catalog, err := ParseCatalog("testdata/gnome-background-properties/timed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gtw, err := ParseXML("testdata/adwaita-timed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gtw.UseCatalogEntry(catalog.Find("/usr/share/backgrounds/gnome/adwaita-timed.xml")); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := GnomeToSimple(gtw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.NameFor("nb_NO") != "Adwaita med tid" || converted.NameFor("pt_BR") != "Adwaita cronometrado" {
+		t.Errorf("expected the localized names to survive the conversion, got %v", converted.Names)
+	}
+	written := (&GCatalog{Wallpapers: []*GCatalogEntry{converted.CatalogEntry("/usr/share/backgrounds/gnome/adwaita-timed.xml")}}).String()
+	if !strings.Contains(written, `<name xml:lang="nb">Adwaita med tid</name>`) {
+		t.Errorf("expected an xml:lang name, got:\n%s", written)
+	}
+}
@@ -0,0 +1,118 @@
+package timed
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// dominant returns the image that is mostly visible, given what is shown
+func dominant(fromFilename, toFilename string, ratio float64) string {
+	if toFilename != "" && ratio >= 0.5 {
+		return toFilename
+	}
+	return fromFilename
+}
+
+// Merge splices two timed wallpapers together. The events from a are used
+// from the "from" time and up to the "upTo" time, while the events from b are
+// used for the rest of the day. At each of the two seams, a transition that
+// lasts for the given seam duration is inserted, from the image that was
+// shown to the image that is about to be shown. If the seam duration is 0,
+// the wallpaper changes directly. The merged timed wallpaper is a Simple
+// Timed Wallpaper, and it is validated before it is returned.
+func Merge(a, b *FatWallpaper, from, upTo time.Time, seam time.Duration) (*FatWallpaper, error) {
+	stwA, err := a.toSimple()
+	if err != nil {
+		return nil, err
+	}
+	stwB, err := b.toSimple()
+	if err != nil {
+		return nil, err
+	}
+	if stwA.usesConditions() || stwB.usesConditions() {
+		return nil, errors.New("can not merge timed wallpapers with events that only apply to some days")
+	}
+	if stwA.usesCron() || stwB.usesCron() {
+		return nil, errors.New("can not merge timed wallpapers with events that are shown according to cron expressions")
+	}
+	start := sinceMidnight(from)
+	end := sinceMidnight(upTo)
+	if start == end {
+		return nil, errors.New("the time range for the first timed wallpaper is empty")
+	}
+	if seam < 0 || seam >= mod24(end-start) || seam >= mod24(start-end) {
+		return nil, fmt.Errorf("the seam duration %s does not fit within the time ranges", dFmt(seam))
+	}
+
+	merged := NewSimple(simpleTimedWallpaperFormatVersion, stwA.Name+"+"+stwB.Name, "")
+
+	// splice adds the events of the given timed wallpaper that are within the
+	// time range, and the seam at the start of the time range
+	splice := func(stw, other *FatWallpaper, start, end time.Duration) error {
+		length := mod24(end - start)
+		within := func(at time.Time) bool {
+			offset := mod24(sinceMidnight(at) - start)
+			return offset >= seam && offset < length
+		}
+		occupied := false
+		for _, s := range stw.Statics {
+			if within(s.At) {
+				merged.Statics = append(merged.Statics, &Static{At: s.At, Filename: s.Filename})
+				occupied = occupied || sinceMidnight(s.At) == mod24(start+seam)
+			}
+		}
+		for _, t := range stw.Transitions {
+			if within(t.From) {
+				nt := *t
+				// Cut transitions that last past the end of the time range
+				if mod24(sinceMidnight(t.From)-start)+t.Duration() > length {
+					nt.UpTo = clockTime(end)
+				}
+				merged.Transitions = append(merged.Transitions, &nt)
+				occupied = occupied || sinceMidnight(t.From) == mod24(start+seam)
+			}
+		}
+
+		// Find the image that was shown right before the seam, and the image that is shown after
+		prevFrom, prevTo, prevRatio, err := other.Shown(clockTime(start))
+		if err != nil {
+			return err
+		}
+		nextFrom, nextTo, nextRatio, err := stw.Shown(clockTime(start + seam))
+		if err != nil {
+			return err
+		}
+		prevImage := dominant(prevFrom, prevTo, prevRatio)
+		nextImage := dominant(nextFrom, nextTo, nextRatio)
+
+		if seam > 0 && prevImage != nextImage {
+			merged.Transitions = append(merged.Transitions, &Transition{From: clockTime(start), UpTo: clockTime(start + seam), FromFilename: prevImage, ToFilename: nextImage, Type: "overlay"})
+		}
+		if !occupied {
+			merged.Statics = append(merged.Statics, &Static{At: clockTime(start + seam), Filename: nextImage})
+		}
+		return nil
+	}
+	if err := splice(stwA, stwB, start, end); err != nil {
+		return nil, err
+	}
+	if err := splice(stwB, stwA, end, start); err != nil {
+		return nil, err
+	}
+
+	// Use a common format string, if possible, to make the output shorter
+	var filenames []string
+	for _, s := range merged.Statics {
+		filenames = append(filenames, s.Filename)
+	}
+	for _, t := range merged.Transitions {
+		filenames = append(filenames, t.FromFilename, t.ToFilename)
+	}
+	merged.Format = commonFormat(filenames)
+
+	if err := merged.Validate(); err != nil {
+		return nil, fmt.Errorf("merged timed wallpaper is invalid: %s", err)
+	}
+	return merged, nil
+}
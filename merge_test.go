@@ -0,0 +1,38 @@
+package timed
+
+import (
+	"fmt"
+	"time"
+)
+
+func ExampleMerge() {
+	adwaita, err := ParseSTW("testdata/adwaita-timed2.stw")
+	if err != nil {
+		panic(err)
+	}
+	comments, err := ParseSTW("testdata/comments.stw")
+	if err != nil {
+		panic(err)
+	}
+	from, _ := time.Parse("15:04", "06:00")
+	upTo, _ := time.Parse("15:04", "18:00")
+	merged, err := Merge(adwaita, comments, from, upTo, 30*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(merged)
+
+	// Output:
+	// stw: 1.0
+	// name: adwaita-timed+comments
+	// format: /usr/share/backgrounds/%s
+	// @05:00: comments/comments-early.jpg
+	// @06:00-06:30: comments/comments-early.jpg .. gnome/adwaita-morning.jpg
+	// @06:30: gnome/adwaita-morning.jpg
+	// @07:00: gnome/adwaita-morning.jpg
+	// @08:00-13:00: gnome/adwaita-morning.jpg .. gnome/adwaita-day.jpg
+	// @13:00: gnome/adwaita-day.jpg
+	// @18:00-18:30: gnome/adwaita-day.jpg .. comments/comments-early.jpg
+	// @18:30: comments/comments-early.jpg
+	// @23:00: comments/comments-late.png
+}
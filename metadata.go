@@ -0,0 +1,77 @@
+package timed
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// metadataFields are the metadata fields that have a meaning for the
+// Simple Timed Wallpaper format, in the order they are written. Other
+// metadata fields must start with "x-".
+var metadataFields = []string{"author", "license", "description", "url", "preview"}
+
+// IsMetadataField checks if the given field is a metadata field, like
+// "author" or "x-collection"
+func IsMetadataField(key string) bool {
+	for _, field := range metadataFields {
+		if key == field {
+			return true
+		}
+	}
+	return strings.HasPrefix(key, "x-") && len(key) > len("x-")
+}
+
+// Meta returns the value of the given metadata field, or "" if it is not set
+func (fw *FatWallpaper) Meta(key string) string {
+	return fw.Metadata[key]
+}
+
+// SetMeta sets the value of the given metadata field. An empty value
+// removes the field. Returns an error if the field is not a metadata field.
+func (fw *FatWallpaper) SetMeta(key, value string) error {
+	if !IsMetadataField(key) {
+		return fmt.Errorf("not a metadata field: %s", key)
+	}
+	if strings.Contains(value, "\n") {
+		return fmt.Errorf("the value of the %s field can not have newlines", key)
+	}
+	if value == "" {
+		delete(fw.Metadata, key)
+		return nil
+	}
+	if fw.Metadata == nil {
+		fw.Metadata = make(map[string]string)
+	}
+	fw.Metadata[key] = value
+	return nil
+}
+
+// MetadataKeys returns the metadata fields that are set, in the order they
+// are written: the fields that have a meaning first, then the "x-" fields,
+// sorted by name
+func (fw *FatWallpaper) MetadataKeys() []string {
+	var keys, others []string
+	for _, field := range metadataFields {
+		if _, ok := fw.Metadata[field]; ok {
+			keys = append(keys, field)
+		}
+	}
+	for key := range fw.Metadata {
+		if strings.HasPrefix(key, "x-") {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
+}
+
+// metadataLines returns the metadata fields as they are written in Simple
+// Timed Wallpaper files
+func (fw *FatWallpaper) metadataLines() []string {
+	var lines []string
+	for _, key := range fw.MetadataKeys() {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, fw.Metadata[key]))
+	}
+	return lines
+}
@@ -0,0 +1,79 @@
+package timed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	data := []byte("stw: 1.0\nname: dunes\nauthor: Alice\nlicense: CC-BY-SA-4.0\nx-collection: deserts\nunknown: ignored\nformat: %s.jpg\n@00:00: night\n@12:00: day\n")
+	stw, err := DataToSimple("dunes.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stw.Meta("author") != "Alice" || stw.Meta("x-collection") != "deserts" || stw.Meta("unknown") != "" {
+		t.Errorf("unexpected metadata: %v", stw.Metadata)
+	}
+	if keys := strings.Join(stw.MetadataKeys(), ","); keys != "author,license,x-collection" {
+		t.Errorf("unexpected metadata keys: %s", keys)
+	}
+	if err := stw.SetMeta("colour", "red"); err == nil {
+		t.Error("expected an error for a field that is not a metadata field")
+	}
+	expected := "stw: 1.0\nname: dunes\nauthor: Alice\nlicense: CC-BY-SA-4.0\nx-collection: deserts\nformat: %s.jpg\n@00:00: night\n@12:00: day"
+	if s := stw.String(); s != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, s)
+	}
+
+	// The author is written to and read from GNOME wallpaper catalogs as the artist
+	if entry := stw.CatalogEntry("/usr/share/backgrounds/dunes.stw"); entry.Artist != "Alice" || entry.Name() != "dunes" {
+		t.Errorf("unexpected catalog entry: %+v", entry)
+	}
+	catalog, err := ParseCatalog("testdata/gnome-background-properties/timed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := catalog.Find("/usr/share/backgrounds/gnome/adwaita-timed.xml")
+	if entry == nil {
+		t.Fatal("expected to find the catalog entry")
+	}
+	gtw, err := ParseXML("testdata/adwaita-timed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gtw.UseCatalogEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := GnomeToSimple(gtw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.Name != "Adwaita Timed" || converted.Meta("author") != "Jakub Steiner" {
+		t.Errorf("expected the catalog name and artist to survive the conversion, got %s and %s", converted.Name, converted.Meta("author"))
+	}
+	s, err := GnomeToSimpleString(gtw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"author: Jakub Steiner", "x-gnome-options: zoom", "x-gnome-pcolor: #3465a4", "x-gnome-scolor: #000000", "x-gnome-shade-type: solid"} {
+		if !strings.Contains(s, line) {
+			t.Errorf("expected %q to be written, got:\n%s", line, s)
+		}
+	}
+
+	// The catalog fields survive a round trip
+	reparsed, err := DataToSimple("adwaita-timed.stw", []byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := reparsed.CatalogEntry(entry.Filename)
+	written.XMLName = entry.XMLName
+	if (&GCatalog{Wallpapers: []*GCatalogEntry{written}}).String() != catalog.String() {
+		t.Errorf("expected the catalog entry to survive a round trip, got %+v", written)
+	}
+
+	// Catalog fields that can not be used as metadata are errors
+	if err := stw.UseCatalogEntry(&GCatalogEntry{PColor: "#000000\n#ffffff"}); err == nil {
+		t.Error("expected an error for a catalog field with a newline")
+	}
+}
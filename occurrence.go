@@ -0,0 +1,212 @@
+package timed
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Occurrence is a static image or transition event at a concrete point in
+// time. For static images, UpTo is when the next event starts.
+type Occurrence struct {
+	From  time.Time
+	UpTo  time.Time
+	Event interface{} // *Static or *Transition
+}
+
+// Window returns how long the occurrence lasts
+func (o *Occurrence) Window() time.Duration {
+	return o.UpTo.Sub(o.From)
+}
+
+// Has checks if the given time is within the occurrence,
+// from From and up to, but not including, UpTo.
+func (o *Occurrence) Has(t time.Time) bool {
+	return !t.Before(o.From) && t.Before(o.UpTo)
+}
+
+// resumeAt returns when an occurrence that has been triggered with the
+// given cooldown since it started should be triggered next, at or after
+// the given time. Returns false if the occurrence is over by then.
+func (o *Occurrence) resumeAt(t time.Time, cooldown time.Duration) (time.Time, bool) {
+	if !o.From.Before(t) {
+		return o.From, true
+	}
+	next := o.UpTo
+	if cooldown > 0 {
+		steps := (t.Sub(o.From) + cooldown - 1) / cooldown
+		next = o.From.Add(steps * cooldown)
+	}
+	return next, next.Before(o.UpTo)
+}
+
+// location returns the time zone that the event times are given in
+func (fw *FatWallpaper) location() *time.Location {
+	if fw.Location != nil {
+		return fw.Location
+	}
+	return time.Local
+}
+
+// SetTimeZone sets the time zone that the event times are given in, by
+// IANA time zone name, like "Europe/Oslo". This overrides the "tz" field.
+func (fw *FatWallpaper) SetTimeZone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	fw.Location = loc
+	fw.inherited.location = nil
+	return nil
+}
+
+// onDay returns the given clock time at the given date, in the time zone
+// of the timed wallpaper. Clock times that are skipped when changing to
+// daylight saving time are moved forward, by the time package.
+func (fw *FatWallpaper) onDay(day time.Time, clock time.Time, addDays int) time.Time {
+	hour, min, sec := clock.Clock()
+	return time.Date(day.Year(), day.Month(), day.Day()+addDays, hour, min, sec, 0, fw.location())
+}
+
+// occurrencesOnDay returns the events that start at the given date, in the
+// time zone of the timed wallpaper. Static images have no UpTo time yet.
+func (fw *FatWallpaper) occurrencesOnDay(day time.Time) []*Occurrence {
+	var occurrences []*Occurrence
+	for _, s := range fw.Statics {
+		at := fw.onDay(day, s.At, 0)
+		occurrences = append(occurrences, &Occurrence{From: at, UpTo: at, Event: s})
+	}
+	for _, t := range fw.Transitions {
+		from := fw.onDay(day, t.From, 0)
+		upTo := fw.onDay(day, t.UpTo, 0)
+		if upTo.Before(from) {
+			// The transition lasts past midnight
+			upTo = fw.onDay(day, t.UpTo, 1)
+		}
+		occurrences = append(occurrences, &Occurrence{From: from, UpTo: upTo, Event: t})
+	}
+	return occurrences
+}
+
+// Occurrences returns all events that are ongoing within the given time
+// range, sorted by when they start. The events are placed at concrete
+// dates in the time zone of the timed wallpaper, so that days with 23 or 25
+// hours, because of daylight saving time, are handled correctly.
+func (fw *FatWallpaper) Occurrences(from, upTo time.Time) ([]*Occurrence, error) {
+	return fw.occurrences(from, upTo, (*FatWallpaper).OnDate)
+}
+
+// blendedOccurrences returns the events that are blended in within the given
+// time range, when the seasons are blended. On days where nothing is blended
+// in, the events for the day are used, as for Occurrences.
+func (fw *FatWallpaper) blendedOccurrences(from, upTo time.Time) ([]*Occurrence, error) {
+	return fw.occurrences(from, upTo, func(stw *FatWallpaper, day time.Time) (*FatWallpaper, error) {
+		blended, _, err := stw.blendOnDate(day)
+		if err != nil || blended != nil {
+			return blended, err
+		}
+		return stw.OnDate(day)
+	})
+}
+
+// occurrences returns all events that are ongoing within the given time
+// range, where the given function returns the events for each day
+func (fw *FatWallpaper) occurrences(from, upTo time.Time, onDate func(*FatWallpaper, time.Time) (*FatWallpaper, error)) ([]*Occurrence, error) {
+	stw := fw
+	if fw.GNOME {
+		var err error
+		stw, err = GnomeToSimple(fw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(stw.Statics) == 0 && len(stw.Transitions) == 0 {
+		return nil, errors.New("got no events")
+	}
+	loc := stw.location()
+
+	// Start a day early, since events from the day before may last into the
+	// time range, and end a day late, to find when the last static image ends.
+	first := from.In(loc)
+	last := upTo.In(loc)
+	day := time.Date(first.Year(), first.Month(), first.Day()-1, 0, 0, 0, 0, loc)
+	end := time.Date(last.Year(), last.Month(), last.Day()+2, 0, 0, 0, 0, loc)
+	var all []*Occurrence
+	for day.Before(end) {
+		// Find the clock times of events that depend on the date
+		events, err := onDate(stw, day)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, events.occurrencesOnDay(day)...)
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].From.Before(all[j].From)
+	})
+
+	// Static images last until the next event starts
+	for i, o := range all {
+		if _, ok := o.Event.(*Static); ok && i+1 < len(all) {
+			o.UpTo = all[i+1].From
+		}
+	}
+
+	var occurrences []*Occurrence
+	for _, o := range all {
+		if o.UpTo.After(from) && o.From.Before(upTo) {
+			occurrences = append(occurrences, o)
+		}
+	}
+	return occurrences, nil
+}
+
+// occurrenceAt returns the event that is ongoing at the given time
+func (fw *FatWallpaper) occurrenceAt(t time.Time) (*Occurrence, error) {
+	occurrences, err := fw.Occurrences(t, t.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return ongoing(occurrences, t)
+}
+
+// ongoing returns the event among the given events that is ongoing at the
+// given time. If events overlap, the one that started last is used.
+func ongoing(occurrences []*Occurrence, t time.Time) (*Occurrence, error) {
+	for i := len(occurrences) - 1; i >= 0; i-- {
+		if occurrences[i].Has(t) {
+			return occurrences[i], nil
+		}
+	}
+	return nil, errors.New("no ongoing event")
+}
+
+// progress returns how far a transition has come at the given time, from 0
+// to 1. Transitions with an elevation range follow the elevation of the sun,
+// and transitions with an easing curve follow the curve.
+func (fw *FatWallpaper) progress(o *Occurrence, now time.Time) float64 {
+	if t, ok := o.Event.(*Transition); ok && t.Easing != nil {
+		return t.Easing.Apply(fw.linearProgress(o, now))
+	}
+	return fw.linearProgress(o, now)
+}
+
+// linearProgress returns how far a transition has come, from 0 to 1,
+// before any easing curve is applied
+func (fw *FatWallpaper) linearProgress(o *Occurrence, now time.Time) float64 {
+	if t, ok := o.Event.(*Transition); ok && t.Elevation != nil {
+		return t.Elevation.Progress(SunElevation(now, fw.Latitude, fw.Longitude))
+	}
+	window := o.Window()
+	if window <= 0 {
+		return 1
+	}
+	ratio := float64(now.Sub(o.From)) / float64(window)
+	if ratio < 0 {
+		return 0
+	}
+	if ratio > 1 {
+		return 1
+	}
+	return ratio
+}
@@ -0,0 +1,106 @@
+package timed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOccurrencesDST(t *testing.T) {
+	stw, err := DataToSimple("dst.stw", []byte("stw: 1.0\ntz: Europe/Oslo\n@00:00: night\n@01:00-04:00: night .. day\n@04:00: day\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oslo := stw.Location
+	if oslo == nil || oslo.String() != "Europe/Oslo" {
+		t.Fatalf("expected the Europe/Oslo time zone, got %v", oslo)
+	}
+	// The clocks are moved forward from 02:00 to 03:00 on this day
+	day := time.Date(2026, 3, 29, 0, 0, 0, 0, oslo)
+	occurrences, err := stw.Occurrences(day, day.Add(23*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(occurrences))
+	}
+	if got := occurrences[1].Window(); got != 2*time.Hour {
+		t.Errorf("expected the transition to last for 2h, got %s", got)
+	}
+	if got := occurrences[2].Window(); got != 20*time.Hour {
+		t.Errorf("expected the day image to last for 20h, got %s", got)
+	}
+	// The clocks are moved back from 03:00 to 02:00 on this day
+	day = time.Date(2026, 10, 25, 0, 0, 0, 0, oslo)
+	occurrences, err = stw.Occurrences(day, day.Add(25*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := occurrences[1].Window(); got != 4*time.Hour {
+		t.Errorf("expected the transition to last for 4h, got %s", got)
+	}
+	// The time zone is written back
+	if reparsed, err := DataToSimple("dst.stw", []byte(stw.String())); err != nil || reparsed.Location.String() != "Europe/Oslo" {
+		t.Errorf("expected the time zone to be kept when writing and parsing: %v", err)
+	}
+}
+
+func TestNextAndPrevEvent(t *testing.T) {
+	stw, err := ParseSTW("testdata/adwaita-timed2.stw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.SetTimeZone("UTC"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 23, 30, 0, 0, time.UTC)
+	e, err := stw.NextEvent(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := e.(*Static); !ok || cFmt(s.At) != "00:00" {
+		t.Errorf("expected the next event to be the static image at 00:00, got %v", e)
+	}
+	e, err = stw.PrevEvent(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr, ok := e.(*Transition); !ok || cFmt(tr.From) != "18:00" {
+		t.Errorf("expected the previous event to be the transition at 18:00, got %v", e)
+	}
+	if got := stw.UntilNext(now); got != 30*time.Minute {
+		t.Errorf("expected 30m until the next event, got %s", got)
+	}
+}
+
+func TestInitialCooldown(t *testing.T) {
+	stw, err := DataToSimple("cooldown.stw", []byte("stw: 1.0\ntz: UTC\n@00:00: night\n@06:00-08:00: night .. day\n@08:00: day\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Half the time that is left of a static image
+	if got := stw.initialCooldown(time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)); got != 2*time.Hour {
+		t.Errorf("expected 2h, got %s", got)
+	}
+	// Half the time between two frames of a transition
+	if got := stw.initialCooldown(time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)); got != 6*time.Minute {
+		t.Errorf("expected 6m, got %s", got)
+	}
+}
+
+func TestResumeAt(t *testing.T) {
+	midnight := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	// A transition from 23:05 to 01:05 that is updated every 12 minutes
+	o := &Occurrence{From: midnight.Add(-55 * time.Minute), UpTo: midnight.Add(65 * time.Minute)}
+	if next, ok := o.resumeAt(midnight, 12*time.Minute); !ok || !next.Equal(midnight.Add(5*time.Minute)) {
+		t.Errorf("expected the transition to continue at 00:05, got %s, %v", next, ok)
+	}
+	// A static image from 23:05 to 01:05 is not triggered again
+	if next, ok := o.resumeAt(midnight, o.Window()); ok {
+		t.Errorf("expected the static image to not be triggered again, got %s", next)
+	}
+	// Occurrences that start after midnight are triggered when they start
+	o = &Occurrence{From: midnight.Add(time.Hour), UpTo: midnight.Add(2 * time.Hour)}
+	if next, ok := o.resumeAt(midnight, o.Window()); !ok || !next.Equal(o.From) {
+		t.Errorf("expected the static image to be triggered at 01:00, got %s, %v", next, ok)
+	}
+}
@@ -0,0 +1,494 @@
+package timed
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Condition selects the days that an event applies to. Events without a
+// condition apply to every day, unless there are events with a condition
+// that matches the day, which then replace them for that day.
+type Condition struct {
+	Dates    []*DateRange   // the dates, or nil for every date
+	Holidays bool           // only on the holidays of the timed wallpaper
+	Months   []time.Month   // the months, or nil for every month
+	Seasons  []Season       // the astronomical seasons, or nil for every season
+	Weekdays []time.Weekday // the days of the week, or nil for every day of the week
+}
+
+// weekOrder is the order that days of the week are written in
+var weekOrder = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+var (
+	weekend  = []time.Weekday{time.Saturday, time.Sunday}
+	weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+)
+
+// parseWeekday parses the name of a day of the week, like "sat" or "saturday"
+func parseWeekday(s string) (time.Weekday, bool) {
+	for _, wd := range weekOrder {
+		name := strings.ToLower(wd.String())
+		if len(s) >= 3 && strings.HasPrefix(name, s) {
+			return wd, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// parseWeekdays parses a comma separated list of days of the week, like
+// "sat,sun" or "mon-fri", or one of the words "weekend" and "weekdays"
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	has := make(map[time.Weekday]bool)
+	for _, item := range strings.Split(strings.ToLower(s), ",") {
+		switch {
+		case item == "weekend":
+			for _, wd := range weekend {
+				has[wd] = true
+			}
+		case item == "weekdays":
+			for _, wd := range weekdays {
+				has[wd] = true
+			}
+		case strings.Contains(item, "-"):
+			fields := strings.SplitN(item, "-", 2)
+			first, ok1 := parseWeekday(fields[0])
+			last, ok2 := parseWeekday(fields[1])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("not a range of days of the week: %s", item)
+			}
+			// Ranges may wrap around the end of the week, like "fri-mon"
+			for wd := first; ; wd = (wd + 1) % 7 {
+				has[wd] = true
+				if wd == last {
+					break
+				}
+			}
+		default:
+			wd, ok := parseWeekday(item)
+			if !ok {
+				return nil, fmt.Errorf("not a day of the week: %s", item)
+			}
+			has[wd] = true
+		}
+	}
+	var days []time.Weekday
+	for _, wd := range weekOrder {
+		if has[wd] {
+			days = append(days, wd)
+		}
+	}
+	return days, nil
+}
+
+// holidaysShare is about how large a share of all days are holidays
+const holidaysShare = 0.05
+
+// parseDates parses a comma separated list of dates and date ranges
+func parseDates(s string) ([]*DateRange, error) {
+	var dates []*DateRange
+	for _, item := range strings.Split(s, ",") {
+		dr, err := ParseDateRange(item)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, dr)
+	}
+	return dates, nil
+}
+
+// addTerm adds a word from a condition to the condition
+func (c *Condition) addTerm(word string) error {
+	if strings.ToLower(word) == "holidays" {
+		c.Holidays = true
+		return nil
+	}
+	if len(word) > 0 && isDigit(word[0]) {
+		dates, err := parseDates(word)
+		if err != nil {
+			return err
+		}
+		if c.Dates != nil {
+			return fmt.Errorf("the dates are given twice: %s", word)
+		}
+		c.Dates = dates
+		return nil
+	}
+	if seasons, err := parseSeasons(word); err == nil {
+		if c.Seasons != nil {
+			return fmt.Errorf("the seasons are given twice: %s", word)
+		}
+		c.Seasons = seasons
+		return nil
+	}
+	if months, err := parseMonths(word); err == nil {
+		if c.Months != nil {
+			return fmt.Errorf("the months are given twice: %s", word)
+		}
+		c.Months = months
+		return nil
+	}
+	days, err := parseWeekdays(word)
+	if err != nil {
+		return fmt.Errorf("not a condition: %s", word)
+	}
+	if c.Weekdays != nil {
+		return fmt.Errorf("the days of the week are given twice: %s", word)
+	}
+	c.Weekdays = days
+	return nil
+}
+
+// ParseCondition parses a condition, like "weekend", "sat,sun", "mon-fri",
+// "holidays", "2026-12-24", "2026-12-20..2027-01-02", "jun-aug" or
+// "winter". A condition may consist of several words, like "winter weekend".
+func ParseCondition(s string) (*Condition, error) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	c := &Condition{}
+	for _, word := range words {
+		if err := c.addTerm(word); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// splitCondition splits the timestamps of an event line into the condition
+// that comes first, if any, and the rest of the timestamps
+func splitCondition(s string) (*Condition, string, error) {
+	var c *Condition
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		i := strings.IndexAny(rest, " \t")
+		if i == -1 {
+			// A date may be given without a time
+			if _, err := parseDates(rest); err != nil {
+				break
+			}
+			i = len(rest)
+		}
+		word := rest[:i]
+		if _, err := ParseCondition(word); err != nil {
+			break
+		}
+		if c == nil {
+			c = &Condition{}
+		}
+		if err := c.addTerm(word); err != nil {
+			return nil, s, err
+		}
+		rest = strings.TrimSpace(rest[i:])
+	}
+	return c, rest, nil
+}
+
+// sameWeekdays checks if two lists of days of the week are the same
+func sameWeekdays(a, b []time.Weekday) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the condition as it is written in Simple Timed Wallpaper files
+func (c *Condition) String() string {
+	if c == nil {
+		return ""
+	}
+	var words []string
+	if c.Dates != nil {
+		var dates []string
+		for _, dr := range c.Dates {
+			dates = append(dates, dr.String())
+		}
+		words = append(words, strings.Join(dates, ","))
+	}
+	if c.Holidays {
+		words = append(words, "holidays")
+	}
+	if c.Months != nil {
+		var names []string
+		for _, m := range c.Months {
+			names = append(names, strings.ToLower(m.String()[:3]))
+		}
+		words = append(words, strings.Join(names, ","))
+	}
+	if c.Seasons != nil {
+		var names []string
+		for _, season := range c.Seasons {
+			names = append(names, season.String())
+		}
+		words = append(words, strings.Join(names, ","))
+	}
+	switch {
+	case c.Weekdays == nil:
+	case sameWeekdays(c.Weekdays, weekend):
+		words = append(words, "weekend")
+	case sameWeekdays(c.Weekdays, weekdays):
+		words = append(words, "weekdays")
+	default:
+		var names []string
+		for _, wd := range c.Weekdays {
+			names = append(names, strings.ToLower(wd.String()[:3]))
+		}
+		words = append(words, strings.Join(names, ","))
+	}
+	return strings.Join(words, " ")
+}
+
+// Matches checks if the condition applies to the given date, for the given
+// timed wallpaper, which has the holidays and the hemisphere. The timed
+// wallpaper may be nil.
+func (c *Condition) Matches(date time.Time, fw *FatWallpaper) bool {
+	return c.matches(date, date, fw)
+}
+
+// matches checks if the condition applies to the given date, where the
+// months and seasons are checked for the given season date instead
+func (c *Condition) matches(date, seasonDate time.Time, fw *FatWallpaper) bool {
+	var (
+		holidays []*DateRange
+		southern bool
+	)
+	if fw != nil {
+		holidays = fw.Holidays
+		southern = fw.southern()
+	}
+	if c.Months != nil {
+		found := false
+		for _, m := range c.Months {
+			if m == seasonDate.Month() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.Seasons != nil {
+		found := false
+		season := SeasonAt(seasonDate, southern)
+		for _, s := range c.Seasons {
+			if s == season {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.Dates != nil {
+		found := false
+		for _, dr := range c.Dates {
+			if dr.Contains(date) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.Holidays && !isHoliday(date, holidays) {
+		return false
+	}
+	if c.Weekdays != nil {
+		found := false
+		for _, wd := range c.Weekdays {
+			if wd == date.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// share returns about how large a share of all days the condition applies
+// to, from 0 to 1. Conditions that apply to fewer days are more specific.
+func (c *Condition) share() float64 {
+	share := 1.0
+	if c.Dates != nil {
+		days := 0
+		for _, dr := range c.Dates {
+			days += dr.days()
+		}
+		share *= float64(days) / 365
+	}
+	if c.Holidays {
+		share *= holidaysShare
+	}
+	if c.Months != nil {
+		share *= float64(len(c.Months)) / 12
+	}
+	if c.Seasons != nil {
+		share *= float64(len(c.Seasons)) / 4
+	}
+	if c.Weekdays != nil {
+		share *= float64(len(c.Weekdays)) / 7
+	}
+	return share
+}
+
+// with returns a condition where the parts that are given in the other
+// condition replace the parts of this condition. This is used for events
+// with a condition that are within a section with a condition.
+func (c *Condition) with(other *Condition) *Condition {
+	if c == nil {
+		return other
+	}
+	if other == nil {
+		return c
+	}
+	combined := *c
+	if other.Dates != nil {
+		combined.Dates = other.Dates
+	}
+	if other.Holidays {
+		combined.Holidays = true
+	}
+	if other.Months != nil {
+		combined.Months = other.Months
+	}
+	if other.Seasons != nil {
+		combined.Seasons = other.Seasons
+	}
+	if other.Weekdays != nil {
+		combined.Weekdays = other.Weekdays
+	}
+	return &combined
+}
+
+// conditionOf returns the condition of a *Static or *Transition
+func conditionOf(e interface{}) *Condition {
+	switch v := e.(type) {
+	case *Static:
+		return v.Condition
+	case *Transition:
+		return v.Condition
+	}
+	return nil
+}
+
+// usesConditions checks if any of the events only apply to some days
+func (fw *FatWallpaper) usesConditions() bool {
+	for _, s := range fw.Statics {
+		if s.Condition != nil {
+			return true
+		}
+	}
+	for _, t := range fw.Transitions {
+		if t.Condition != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// usesHolidays checks if any of the events only apply to holidays
+func (fw *FatWallpaper) usesHolidays() bool {
+	for _, e := range fw.events() {
+		if c := conditionOf(e); c != nil && c.Holidays {
+			return true
+		}
+	}
+	return false
+}
+
+// conditions returns the distinct conditions of the events, as strings,
+// where "" is used for events that apply to every day
+func (fw *FatWallpaper) conditions() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, e := range fw.events() {
+		key := conditionOf(e).String()
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// keepCondition keeps only the events with the given condition, as a string
+func (fw *FatWallpaper) keepCondition(key string) {
+	var statics []*Static
+	for _, s := range fw.Statics {
+		if s.Condition.String() == key {
+			statics = append(statics, s)
+		}
+	}
+	var transitions []*Transition
+	for _, t := range fw.Transitions {
+		if t.Condition.String() == key {
+			transitions = append(transitions, t)
+		}
+	}
+	fw.Statics = statics
+	fw.Transitions = transitions
+}
+
+// schedule returns a copy of the Simple Timed Wallpaper with only the events
+// that have the given condition, as a string. If no events have the given
+// condition, the events that apply to every day are used. The conditions
+// are removed from the returned events.
+func (fw *FatWallpaper) schedule(key string) *FatWallpaper {
+	stw := fw.Copy()
+	found := false
+	for _, k := range fw.conditions() {
+		found = found || k == key
+	}
+	if !found {
+		key = ""
+	}
+	stw.keepCondition(key)
+	for _, s := range stw.Statics {
+		s.Condition = nil
+	}
+	for _, t := range stw.Transitions {
+		t.Condition = nil
+	}
+	return stw
+}
+
+// selectSchedule keeps only the events that apply to the given date. If
+// several conditions match the date, the most specific one is used, like
+// "sat" instead of "weekend". If no conditions match, the events without a
+// condition are kept.
+func (fw *FatWallpaper) selectSchedule(date time.Time) {
+	if !fw.usesConditions() {
+		return
+	}
+	date = date.In(fw.location())
+	fw.keepCondition(fw.selectCondition(date, date).String())
+}
+
+// selectCondition finds the most specific condition that matches the given
+// date, where the months and seasons are checked for the given season date.
+// Returns nil if no conditions match.
+func (fw *FatWallpaper) selectCondition(date, seasonDate time.Time) *Condition {
+	var selected *Condition
+	for _, e := range fw.events() {
+		c := conditionOf(e)
+		if c == nil || !c.matches(date, seasonDate, fw) {
+			continue
+		}
+		if selected == nil || c.share() < selected.share() || (c.share() == selected.share() && c.String() < selected.String()) {
+			selected = c
+		}
+	}
+	return selected
+}
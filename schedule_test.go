@@ -0,0 +1,188 @@
+package timed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeekdays(t *testing.T) {
+	stw, err := DataToSimple("weekdays.stw", []byte("stw: 1.0\ntz: UTC\n@07:00: morning\n@19:00: evening\n[weekend]\n@09:00: weekend-morning\n@21:00: weekend-evening\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// A Friday evening
+	friday := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	e, err := stw.NextEvent(friday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := e.(*Static); !ok || s.Filename != "weekend-morning" {
+		t.Errorf("expected the next event to be weekend-morning, got %v", e)
+	}
+	if d := stw.UntilNext(friday); d != 13*time.Hour {
+		t.Errorf("expected 13h until the next event, got %s", d)
+	}
+	// A Monday morning
+	monday := time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC)
+	e, err = stw.PrevEvent(monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := e.(*Static); !ok || s.Filename != "weekend-evening" {
+		t.Errorf("expected the previous event to be weekend-evening, got %v", e)
+	}
+	if from, _, _, _ := stw.Shown(monday.Add(2 * time.Hour)); from != "morning" {
+		t.Errorf("expected morning to be shown on Monday at 08:00, got %s", from)
+	}
+
+	// The section is written as a condition on each event
+	if s := stw.String(); !strings.Contains(s, "@weekend 09:00: weekend-morning") || !strings.HasPrefix(s, "stw: 1.2") {
+		t.Errorf("expected the events to be written with conditions, as version 1.2, got:\n%s", s)
+	}
+	reparsed, err := DataToSimple("weekdays.stw", []byte(stw.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Equivalent(stw, reparsed, 0); !ok || err != nil {
+		t.Errorf("expected the events to be kept when writing and parsing: %v", err)
+	}
+
+	// Events for different days may start at the same time, and the most
+	// specific condition is used
+	stw, err = DataToSimple("weekdays.stw", []byte("stw: 1.0\n@07:00: morning\n@sat,sun 07:00: weekend\n@sunday 07:00: sunday\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.Validate(); err != nil {
+		t.Error(err)
+	}
+	sunday := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	if from, _, _, _ := stw.Shown(sunday); from != "sunday" {
+		t.Errorf("expected sunday to be shown on a Sunday, got %s", from)
+	}
+	if from, _, _, _ := stw.Shown(sunday.Add(-24 * time.Hour)); from != "weekend" {
+		t.Errorf("expected weekend to be shown on a Saturday, got %s", from)
+	}
+	if _, err := DataToSimple("weekdays.stw", []byte("stw: 1.0\n[someday]\n@07:00: morning\n")); err == nil {
+		t.Error("expected an error for an unknown section")
+	}
+}
+
+func TestDatesAndHolidays(t *testing.T) {
+	for _, filename := range []string{"holidays.ics", "holidays.txt"} {
+		stw, err := DataToSimple("testdata/dates.stw", []byte("stw: 1.0\ntz: UTC\nholidays: "+filename+"\n@07:00: morning\n@2026-12-24: christmas\n[2026-12-31..2027-01-01]\n@00:00: fireworks\n[holidays]\n@08:00: holiday\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stw.Validate(); err != nil {
+			t.Error(err)
+		}
+		shown := func(date string) string {
+			at, err := time.Parse("2006-01-02 15:04", date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, _, _, err := stw.Shown(at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return from
+		}
+		for date, expected := range map[string]string{
+			"2026-12-23 12:00": "morning",
+			"2026-12-24 12:00": "christmas",
+			"2026-12-25 12:00": "holiday",
+			"2026-12-26 12:00": "holiday",
+			"2026-12-31 12:00": "fireworks",
+			"2027-01-01 12:00": "fireworks",
+			"2026-04-06 12:00": "holiday",
+			"2026-04-07 12:00": "morning",
+		} {
+			if got := shown(date); got != expected {
+				t.Errorf("%s: expected %s to be shown at %s, got %s", filename, expected, date, got)
+			}
+		}
+		// Christmas repeats every year in the iCalendar file
+		if got := shown("2030-12-25 12:00"); (got == "holiday") != (filename == "holidays.ics") {
+			t.Errorf("%s: got %s at Christmas in 2030", filename, got)
+		}
+		if s := stw.String(); !strings.Contains(s, "holidays: "+filename) || !strings.Contains(s, "@2026-12-24 00:00: christmas") {
+			t.Errorf("expected the holidays field and the date to be written, got:\n%s", s)
+		}
+	}
+	if _, err := DataToSimple("testdata/dates.stw", []byte("stw: 1.0\n[holidays]\n@08:00: holiday\n")); err == nil {
+		t.Error("expected an error when there is no holidays field")
+	}
+}
+
+func TestSeasons(t *testing.T) {
+	if s := SeasonAt(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), false); s != Summer {
+		t.Errorf("expected summer in July, got %s", s)
+	}
+	if s := SeasonAt(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), true); s != Winter {
+		t.Errorf("expected winter in July at the southern hemisphere, got %s", s)
+	}
+	if s := SeasonAt(time.Date(2026, 3, 19, 0, 0, 0, 0, time.UTC), false); s != Winter {
+		t.Errorf("expected winter right before the March equinox, got %s", s)
+	}
+	if s := SeasonAt(time.Date(2026, 3, 21, 0, 0, 0, 0, time.UTC), false); s != Spring {
+		t.Errorf("expected spring right after the March equinox, got %s", s)
+	}
+
+	data := "stw: 1.0\ntz: UTC\nblend-days: 10\n@07:00: day\n[winter]\n@07:00: snow\n[jun-aug]\n@07:00: beach\n"
+	stw, err := DataToSimple("seasons.stw", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stw.Validate(); err != nil {
+		t.Error(err)
+	}
+	shown := func(stw *FatWallpaper, date string) string {
+		at, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, _, _, err := stw.Shown(at.Add(12 * time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return from
+	}
+	for date, expected := range map[string]string{"2026-01-15": "snow", "2026-04-15": "day", "2026-07-15": "beach", "2026-09-10": "day"} {
+		if got := shown(stw, date); got != expected {
+			t.Errorf("expected %s to be shown at %s, got %s", expected, date, got)
+		}
+	}
+	south, err := DataToSimple("seasons.stw", []byte(data+"hemisphere: south\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shown(south, "2026-09-10"); got != "snow" {
+		t.Errorf("expected snow in September at the southern hemisphere, got %s", got)
+	}
+
+	// The events for autumn and winter are blended around the December solstice
+	blend := func(date string) (string, float64) {
+		at, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stw.seasonBlend(at)
+	}
+	if _, ratio := blend("2026-12-01"); ratio != 0 {
+		t.Errorf("expected no blending in the start of December, got %f", ratio)
+	}
+	if key, ratio := blend("2026-12-18"); key != "winter" || ratio <= 0 || ratio >= 0.5 {
+		t.Errorf("expected some of the winter events to be blended in, got %q %f", key, ratio)
+	}
+	if key, ratio := blend("2026-12-23"); key != "" || ratio <= 0 || ratio > 0.5 {
+		t.Errorf("expected some of the autumn events to be blended in, got %q %f", key, ratio)
+	}
+	if s := stw.String(); !strings.Contains(s, "blend-days: 10") || !strings.Contains(s, "@jun,jul,aug 07:00: beach") {
+		t.Errorf("expected the seasons to be written, got:\n%s", s)
+	}
+}
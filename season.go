@@ -0,0 +1,200 @@
+package timed
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Season is an astronomical season
+type Season int
+
+// The astronomical seasons, which start at the equinoxes and solstices
+const (
+	Spring Season = iota
+	Summer
+	Autumn
+	Winter
+)
+
+// seasonNames are the names of the seasons, as written in Simple Timed
+// Wallpaper files
+var seasonNames = []string{"spring", "summer", "autumn", "winter"}
+
+// String returns the name of the season
+func (s Season) String() string {
+	if s < Spring || s > Winter {
+		return "unknown"
+	}
+	return seasonNames[s]
+}
+
+// sunLongitude returns the ecliptic longitude of the sun, in degrees, at the
+// given time. It is 0 at the March equinox and 90 at the June solstice.
+func sunLongitude(t time.Time) float64 {
+	d := julianDay(t) - j2000
+	anomaly := math.Mod(357.5291+0.98560028*d, 360) * degrees
+	center := 1.9148*math.Sin(anomaly) + 0.0200*math.Sin(2*anomaly) + 0.0003*math.Sin(3*anomaly)
+	longitude := math.Mod(anomaly/degrees+center+180+102.9372, 360)
+	if longitude < 0 {
+		longitude += 360
+	}
+	return longitude
+}
+
+// SeasonAt returns the astronomical season at the given date, at the
+// northern or southern hemisphere
+func SeasonAt(date time.Time, southern bool) Season {
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, date.Location())
+	season := Season(sunLongitude(noon) / 90)
+	if southern {
+		season = (season + 2) % 4
+	}
+	return season
+}
+
+// parseSeasons parses a comma separated list of seasons, like "summer,autumn".
+// "fall" is the same as "autumn".
+func parseSeasons(s string) ([]Season, error) {
+	has := make(map[Season]bool)
+	for _, item := range strings.Split(strings.ToLower(s), ",") {
+		if item == "fall" {
+			item = "autumn"
+		}
+		found := false
+		for i, name := range seasonNames {
+			if item == name {
+				has[Season(i)] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("not a season: %s", item)
+		}
+	}
+	var seasons []Season
+	for season := Spring; season <= Winter; season++ {
+		if has[season] {
+			seasons = append(seasons, season)
+		}
+	}
+	return seasons, nil
+}
+
+// parseMonth parses the name of a month, like "jun" or "june"
+func parseMonth(s string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		if len(s) >= 3 && strings.HasPrefix(strings.ToLower(m.String()), s) {
+			return m, true
+		}
+	}
+	return time.January, false
+}
+
+// parseMonths parses a comma separated list of months, like "dec,jan,feb"
+// or "jun-aug"
+func parseMonths(s string) ([]time.Month, error) {
+	has := make(map[time.Month]bool)
+	for _, item := range strings.Split(strings.ToLower(s), ",") {
+		if strings.Contains(item, "-") {
+			fields := strings.SplitN(item, "-", 2)
+			first, ok1 := parseMonth(fields[0])
+			last, ok2 := parseMonth(fields[1])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("not a range of months: %s", item)
+			}
+			// Ranges may wrap around the end of the year, like "nov-feb"
+			for m := first; ; m = m%12 + 1 {
+				has[m] = true
+				if m == last {
+					break
+				}
+			}
+			continue
+		}
+		m, ok := parseMonth(item)
+		if !ok {
+			return nil, fmt.Errorf("not a month: %s", item)
+		}
+		has[m] = true
+	}
+	var months []time.Month
+	for m := time.January; m <= time.December; m++ {
+		if has[m] {
+			months = append(months, m)
+		}
+	}
+	return months, nil
+}
+
+// southern checks if the timed wallpaper is for the southern hemisphere,
+// either because of the hemisphere field or because of the latitude
+func (fw *FatWallpaper) southern() bool {
+	switch fw.Hemisphere {
+	case "south":
+		return true
+	case "north":
+		return false
+	}
+	return fw.Latitude < 0
+}
+
+// usesSeasons checks if any of the events only apply to some months or seasons
+func (fw *FatWallpaper) usesSeasons() bool {
+	for _, e := range fw.events() {
+		if c := conditionOf(e); c != nil && (c.Months != nil || c.Seasons != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// seasonBlend finds the events that are blended in at the given date, when
+// the seasons are blended. The returned string is the condition of the
+// events that are blended in, and the returned float64 is how much they
+// are blended in, from 0 to 0.5. The blending lasts for BlendDays days,
+// centered around the day where the events for the season change.
+func (fw *FatWallpaper) seasonBlend(date time.Time) (string, float64) {
+	if fw.BlendDays <= 0 || !fw.usesSeasons() {
+		return "", 0
+	}
+	date = date.In(fw.location())
+	current := fw.selectCondition(date, date).String()
+	// Count how many of the days around the given date that would use other
+	// events, if only the season was changed
+	counts := make(map[string]int)
+	for i := 0; i < fw.BlendDays; i++ {
+		seasonDate := time.Date(date.Year(), date.Month(), date.Day()+i-fw.BlendDays/2, 12, 0, 0, 0, date.Location())
+		if key := fw.selectCondition(date, seasonDate).String(); key != current {
+			counts[key]++
+		}
+	}
+	other, most := "", 0
+	for key, count := range counts {
+		if count > most || (count == most && key < other) {
+			other, most = key, count
+		}
+	}
+	if most == 0 {
+		return "", 0
+	}
+	return other, float64(most) / float64(fw.BlendDays)
+}
+
+// blendOnDate returns the events that are blended in at the given date,
+// when the seasons are blended, and how much they are blended in. If no
+// events are blended in, nil is returned.
+func (fw *FatWallpaper) blendOnDate(date time.Time) (*FatWallpaper, float64, error) {
+	stw, err := fw.toSimple()
+	if err != nil {
+		return nil, 0, err
+	}
+	key, ratio := stw.seasonBlend(date)
+	if ratio == 0 {
+		return nil, 0, nil
+	}
+	stw.keepCondition(key)
+	stw.placeSolarTimes(date)
+	return stw, ratio, nil
+}
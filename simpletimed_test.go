@@ -2,6 +2,8 @@ package timed
 
 import (
 	"fmt"
+	"testing"
+	"time"
 )
 
 func ExampleParseSTW() {
@@ -21,3 +23,22 @@ func ExampleParseSTW() {
 	// adwaita-timed
 	// comments
 }
+
+func TestSeconds(t *testing.T) {
+	data := []byte("stw: 1.0\nformat: %s.jpg\n@00:00:01: one\n@00:14:16 - 00:14:21: one .. two\n@00:14:21: two\n@07:00:30\n")
+	stw, err := DataToSimple("seconds.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stw.Transitions[0].Duration(); got != 5*time.Second {
+		t.Errorf("expected the transition to last for 5s, got %s", got)
+	}
+	// For compatibility with version 1.0, this is the filename "30" at 07:00
+	if got := stw.Statics[2]; cFmt(got.At) != "07:00" || got.Filename != "30.jpg" {
+		t.Errorf("expected 30.jpg at 07:00, got %s at %s", got.Filename, cFmt(got.At))
+	}
+	expected := "stw: 1.1\nname: \nformat: %s.jpg\n@00:00:01: one\n@00:14:16-00:14:21: one .. two\n@00:14:21: two\n@07:00: 30"
+	if stw.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, stw)
+	}
+}
@@ -0,0 +1,400 @@
+package timed
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of solar events, that can be used instead of clock times in Simple
+// Timed Wallpaper files
+const (
+	SunriseEvent          = "sunrise"
+	SunsetEvent           = "sunset"
+	NoonEvent             = "noon"
+	CivilDawnEvent        = "civil-dawn"
+	CivilDuskEvent        = "civil-dusk"
+	NauticalDawnEvent     = "nautical-dawn"
+	NauticalDuskEvent     = "nautical-dusk"
+	AstronomicalDawnEvent = "astronomical-dawn"
+	AstronomicalDuskEvent = "astronomical-dusk"
+)
+
+// sunElevations has the elevation of the sun, in degrees, at each solar
+// event, and if the sun is rising or setting at that point
+var sunElevations = map[string]struct {
+	elevation float64
+	rising    bool
+}{
+	SunriseEvent:          {-0.833, true},
+	SunsetEvent:           {-0.833, false},
+	CivilDawnEvent:        {-6, true},
+	CivilDuskEvent:        {-6, false},
+	NauticalDawnEvent:     {-12, true},
+	NauticalDuskEvent:     {-12, false},
+	AstronomicalDawnEvent: {-18, true},
+	AstronomicalDuskEvent: {-18, false},
+}
+
+// solarReferenceDate is the date that is used for finding the nominal clock
+// time of solar events, which is used when sorting and comparing events
+var solarReferenceDate = time.Date(2000, 3, 20, 12, 0, 0, 0, time.UTC)
+
+const (
+	j2000      = 2451545.0 // the Julian day at 2000-01-01 12:00 UTC
+	unixEpoch  = 2440587.5 // the Julian day at 1970-01-01 00:00 UTC
+	degrees    = math.Pi / 180
+	earthTilt  = 23.4397 // the obliquity of the ecliptic, in degrees
+	secsPerDay = 86400
+)
+
+// julianDay converts a timestamp to a Julian day
+func julianDay(t time.Time) float64 {
+	return float64(t.Unix())/secsPerDay + unixEpoch
+}
+
+// fromJulianDay converts a Julian day to a timestamp in the given time zone
+func fromJulianDay(jd float64, loc *time.Location) time.Time {
+	return time.Unix(0, int64(math.Round((jd-unixEpoch)*secsPerDay))*int64(time.Second)).In(loc)
+}
+
+// solarTransit finds the Julian day of the solar noon, and the declination
+// of the sun in radians, at the given date and longitude
+func solarTransit(date time.Time, longitude float64) (float64, float64) {
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, date.Location())
+	// The mean solar noon at the given longitude, as days since J2000
+	n := math.Round(julianDay(noon) - j2000)
+	meanNoon := n - longitude/360
+	// The mean anomaly of the sun, the equation of the center and the ecliptic longitude
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360) * degrees
+	center := 1.9148*math.Sin(anomaly) + 0.0200*math.Sin(2*anomaly) + 0.0003*math.Sin(3*anomaly)
+	eclipticLongitude := math.Mod(anomaly/degrees+center+180+102.9372, 360) * degrees
+	transit := j2000 + meanNoon + 0.0053*math.Sin(anomaly) - 0.0069*math.Sin(2*eclipticLongitude)
+	declination := math.Asin(math.Sin(eclipticLongitude) * math.Sin(earthTilt*degrees))
+	return transit, declination
+}
+
+// SolarNoon returns when the sun is at its highest on the given date, at the
+// given longitude in degrees, where east is positive. The returned time is in
+// the time zone of the given date.
+func SolarNoon(date time.Time, longitude float64) time.Time {
+	transit, _ := solarTransit(date, longitude)
+	return fromJulianDay(transit, date.Location())
+}
+
+// SunAtElevation returns when the sun passes the given elevation, in degrees,
+// on the given date, either when rising or when setting. The latitude and
+// longitude are in degrees, where north and east are positive. The returned
+// time is in the time zone of the given date. Returns false if the sun does
+// not pass the given elevation on that date, like in the polar summer.
+func SunAtElevation(date time.Time, latitude, longitude, elevation float64, rising bool) (time.Time, bool) {
+	transit, declination := solarTransit(date, longitude)
+	lat := latitude * degrees
+	cosHourAngle := (math.Sin(elevation*degrees) - math.Sin(lat)*math.Sin(declination)) / (math.Cos(lat) * math.Cos(declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) / degrees
+	if rising {
+		return fromJulianDay(transit-hourAngle/360, date.Location()), true
+	}
+	return fromJulianDay(transit+hourAngle/360, date.Location()), true
+}
+
+// Sunrise returns when the sun rises on the given date, at the given position
+func Sunrise(date time.Time, latitude, longitude float64) (time.Time, bool) {
+	return SolarEventTime(SunriseEvent, date, latitude, longitude)
+}
+
+// Sunset returns when the sun sets on the given date, at the given position
+func Sunset(date time.Time, latitude, longitude float64) (time.Time, bool) {
+	return SolarEventTime(SunsetEvent, date, latitude, longitude)
+}
+
+// SolarEventTime returns when the named solar event happens on the given
+// date, at the given position. Returns false if the event does not happen on
+// that date, or if the name is not a known solar event.
+func SolarEventTime(name string, date time.Time, latitude, longitude float64) (time.Time, bool) {
+	if name == NoonEvent {
+		return SolarNoon(date, longitude), true
+	}
+	e, ok := sunElevations[name]
+	if !ok {
+		return time.Time{}, false
+	}
+	return SunAtElevation(date, latitude, longitude, e.elevation, e.rising)
+}
+
+// SolarTime is a time of day that is relative to a solar event, like
+// 30 minutes before sunrise
+type SolarTime struct {
+	Event  string
+	Offset time.Duration
+}
+
+// solarEventNames returns the names of all solar events, longest first
+func solarEventNames() []string {
+	names := []string{NoonEvent}
+	for name := range sunElevations {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j]) || (len(names[i]) == len(names[j]) && names[i] < names[j])
+	})
+	return names
+}
+
+// ParseSolarTime parses a solar event name, optionally followed by an offset,
+// like "sunrise", "sunset+1h" or "civil-dusk-30m"
+func ParseSolarTime(s string) (*SolarTime, error) {
+	s = strings.TrimSpace(s)
+	for _, name := range solarEventNames() {
+		if !strings.HasPrefix(s, name) {
+			continue
+		}
+		st := &SolarTime{Event: name}
+		rest := strings.TrimSpace(s[len(name):])
+		if rest == "" {
+			return st, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			return nil, fmt.Errorf("invalid offset after %s: %s", name, rest)
+		}
+		offset, err := time.ParseDuration(strings.Replace(rest, " ", "", -1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset after %s: %s", name, rest)
+		}
+		st.Offset = offset
+		return st, nil
+	}
+	return nil, fmt.Errorf("not a solar event: %s", s)
+}
+
+// String returns the solar time as it is written in Simple Timed Wallpaper files
+func (st *SolarTime) String() string {
+	if st.Offset == 0 {
+		return st.Event
+	}
+	if st.Offset < 0 {
+		return st.Event + "-" + dFmt(-st.Offset)
+	}
+	return st.Event + "+" + dFmt(st.Offset)
+}
+
+// On returns when the solar time happens on the given date, at the given
+// position, in the time zone of the given date. Returns false if the solar
+// event does not happen on that date.
+func (st *SolarTime) On(date time.Time, latitude, longitude float64) (time.Time, bool) {
+	t, ok := SolarEventTime(st.Event, date, latitude, longitude)
+	if !ok {
+		return time.Time{}, false
+	}
+	return t.Add(st.Offset), true
+}
+
+// usesSolarTimes checks if any of the events are relative to solar events
+func (fw *FatWallpaper) usesSolarTimes() bool {
+	for _, s := range fw.Statics {
+		if s.Solar != nil {
+			return true
+		}
+	}
+	for _, t := range fw.Transitions {
+		if t.FromSolar != nil || t.UpToSolar != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// solarClock returns the clock time of a solar time at the given date, in
+// the time zone of the timed wallpaper
+func (fw *FatWallpaper) solarClock(st *SolarTime, date time.Time) (time.Time, bool) {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, fw.location())
+	t, ok := st.On(date, fw.Latitude, fw.Longitude)
+	if !ok {
+		return time.Time{}, false
+	}
+	return clockTime(sinceMidnight(t)), true
+}
+
+// nominalSolarClock returns the nominal clock time of a solar time, which
+// is the clock time at the solar reference date. If the solar event does
+// not happen at that date, like at high latitudes, the time where the sun
+// is closest to the elevation of the event is used instead: solar midnight
+// if the sun is always above it, or solar noon if the sun is always below.
+func (fw *FatWallpaper) nominalSolarClock(st *SolarTime) time.Time {
+	if at, ok := fw.solarClock(st, solarReferenceDate); ok {
+		return at
+	}
+	date := time.Date(solarReferenceDate.Year(), solarReferenceDate.Month(), solarReferenceDate.Day(), 12, 0, 0, 0, fw.location())
+	closest := SolarNoon(date, fw.Longitude)
+	if midnight := closest.Add(12 * time.Hour); SunElevation(midnight, fw.Latitude, fw.Longitude) > sunElevations[st.Event].elevation {
+		closest = midnight
+	}
+	return clockTime(sinceMidnight(closest.Add(st.Offset)))
+}
+
+// updateSolarTimes sets the clock times of the events that are relative to
+// solar events to the nominal clock times, see nominalSolarClock. The
+// nominal times are used for sorting and comparing events, while the event
+// loop finds the solar times for every day, and skips the events on the
+// days where they do not happen. No events are removed here.
+func (fw *FatWallpaper) updateSolarTimes() {
+	for _, s := range fw.Statics {
+		if s.Solar != nil {
+			s.At = fw.nominalSolarClock(s.Solar)
+		}
+	}
+	for _, t := range fw.Transitions {
+		if t.FromSolar != nil {
+			t.From = fw.nominalSolarClock(t.FromSolar)
+		}
+		if t.UpToSolar != nil {
+			t.UpTo = fw.nominalSolarClock(t.UpToSolar)
+		}
+	}
+}
+
+// OnDate returns a copy of the Simple Timed Wallpaper with only the events
+// that apply to the given date, where the events that are relative to solar
+// events are placed at the clock times for that date. Events that do not
+// happen on that date, like sunset in the polar summer, are left out, and
+// static images that are shown according to cron expressions are repeated
+// at the times where the cron expressions apply.
+func (fw *FatWallpaper) OnDate(date time.Time) (*FatWallpaper, error) {
+	stw, err := fw.toSimple()
+	if err != nil {
+		return nil, err
+	}
+	stw.selectSchedule(date)
+	stw.placeSolarTimes(date)
+	stw.expandCron(date)
+	return stw, nil
+}
+
+// placeSolarTimes places the events that are relative to solar events at
+// the clock times for the given date, and removes the events that do not
+// happen on that date
+func (fw *FatWallpaper) placeSolarTimes(date time.Time) {
+	if !fw.usesSolarTimes() {
+		return
+	}
+	statics := fw.Statics[:0]
+	for _, s := range fw.Statics {
+		if s.Solar != nil {
+			at, ok := fw.solarClock(s.Solar, date)
+			if !ok {
+				continue
+			}
+			s.At = at
+		}
+		statics = append(statics, s)
+	}
+	fw.Statics = statics
+	transitions := fw.Transitions[:0]
+	for _, t := range fw.Transitions {
+		if t.FromSolar != nil {
+			from, ok := fw.solarClock(t.FromSolar, date)
+			if !ok {
+				continue
+			}
+			t.From = from
+		}
+		if t.UpToSolar != nil {
+			upTo, ok := fw.solarClock(t.UpToSolar, date)
+			if !ok {
+				continue
+			}
+			t.UpTo = upTo
+		}
+		transitions = append(transitions, t)
+	}
+	fw.Transitions = transitions
+}
+
+// SunElevation returns the elevation of the center of the sun above the
+// horizon, in degrees, at the given time and position. The latitude and
+// longitude are in degrees, where north and east are positive.
+func SunElevation(t time.Time, latitude, longitude float64) float64 {
+	d := julianDay(t) - j2000
+	anomaly := math.Mod(357.5291+0.98560028*d, 360) * degrees
+	center := 1.9148*math.Sin(anomaly) + 0.0200*math.Sin(2*anomaly) + 0.0003*math.Sin(3*anomaly)
+	eclipticLongitude := math.Mod(anomaly/degrees+center+180+102.9372, 360) * degrees
+	tilt := earthTilt * degrees
+	declination := math.Asin(math.Sin(eclipticLongitude) * math.Sin(tilt))
+	rightAscension := math.Atan2(math.Sin(eclipticLongitude)*math.Cos(tilt), math.Cos(eclipticLongitude))
+	siderealTime := math.Mod(280.16+360.9856235*d+longitude, 360) * degrees
+	hourAngle := siderealTime - rightAscension
+	lat := latitude * degrees
+	return math.Asin(math.Sin(lat)*math.Sin(declination)+math.Cos(lat)*math.Cos(declination)*math.Cos(hourAngle)) / degrees
+}
+
+// ElevationRange is a range of elevations of the sun, in degrees. A
+// transition with an elevation range follows the position of the sun,
+// instead of the clock, so that it is complete when the sun reaches UpTo.
+type ElevationRange struct {
+	From float64
+	UpTo float64
+}
+
+// ParseElevationRange parses an elevation range on the form
+// "elevation(-6,10)", where the elevations are given in degrees
+func ParseElevationRange(s string) (*ElevationRange, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "elevation(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("not an elevation range: %s", s)
+	}
+	fields := strings.Split(s[len("elevation("):len(s)-1], ",")
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("an elevation range must have two elevations: %s", s)
+	}
+	from, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid elevation in %s: %s", s, fields[0])
+	}
+	upTo, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid elevation in %s: %s", s, fields[1])
+	}
+	if from == upTo {
+		return nil, fmt.Errorf("the elevations in an elevation range must differ: %s", s)
+	}
+	return &ElevationRange{from, upTo}, nil
+}
+
+// String returns the elevation range as it is written in Simple Timed Wallpaper files
+func (er *ElevationRange) String() string {
+	return fmt.Sprintf("elevation(%s,%s)", strconv.FormatFloat(er.From, 'f', -1, 64), strconv.FormatFloat(er.UpTo, 'f', -1, 64))
+}
+
+// Progress returns how far the sun has come from the From elevation to the
+// UpTo elevation, from 0 to 1. The sun may be rising or setting.
+func (er *ElevationRange) Progress(elevation float64) float64 {
+	ratio := (elevation - er.From) / (er.UpTo - er.From)
+	if ratio < 0 {
+		return 0
+	}
+	if ratio > 1 {
+		return 1
+	}
+	return ratio
+}
+
+// usesSunPosition checks if any of the events depend on the position of
+// the sun, either by being relative to solar events or by following the
+// elevation of the sun
+func (fw *FatWallpaper) usesSunPosition() bool {
+	if fw.usesSolarTimes() {
+		return true
+	}
+	for _, t := range fw.Transitions {
+		if t.Elevation != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package timed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSolarEventTimes(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	near := func(name string, got time.Time, ok bool, expected string) {
+		t.Helper()
+		if !ok {
+			t.Errorf("expected %s to happen", name)
+			return
+		}
+		e, err := time.ParseInLocation("2006-01-02 15:04", expected, oslo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := got.Sub(e); diff < -2*time.Minute || diff > 2*time.Minute {
+			t.Errorf("expected %s at about %s, got %s", name, expected, got)
+		}
+	}
+	winter := time.Date(2026, 12, 21, 0, 0, 0, 0, oslo)
+	sunrise, ok := Sunrise(winter, 59.91, 10.75)
+	near("sunrise", sunrise, ok, "2026-12-21 09:18")
+	sunset, ok := Sunset(winter, 59.91, 10.75)
+	near("sunset", sunset, ok, "2026-12-21 15:12")
+	summer := time.Date(2026, 6, 21, 0, 0, 0, 0, oslo)
+	sunrise, ok = Sunrise(summer, 59.91, 10.75)
+	near("sunrise", sunrise, ok, "2026-06-21 03:54")
+	noon, ok := SolarEventTime(NoonEvent, summer, 59.91, 10.75)
+	near("noon", noon, ok, "2026-06-21 13:19")
+
+	// Midnight sun in Tromsø
+	if _, ok := Sunset(summer, 69.65, 18.96); ok {
+		t.Error("expected no sunset in Tromsø at midsummer")
+	}
+	if _, ok := SolarEventTime(NauticalDuskEvent, summer, 59.91, 10.75); ok {
+		t.Error("expected no nautical dusk in Oslo at midsummer")
+	}
+}
+
+func TestSolarSTW(t *testing.T) {
+	stw, err := DataToSimple("solar.stw", []byte("stw: 1.0\ntz: Europe/Oslo\nlatitude: 59.91\nlongitude: 10.75\nformat: %s.jpg\n@sunrise-30m: morning\n@noon: day\n@sunset .. civil-dusk: day .. night\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stw.Statics[0].Solar == nil || stw.Statics[0].Solar.String() != "sunrise-30m" {
+		t.Errorf("expected the first static image to be at sunrise-30m, got %v", stw.Statics[0].Solar)
+	}
+	day := time.Date(2026, 12, 21, 0, 0, 0, 0, stw.Location)
+	occurrences, err := stw.Occurrences(day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(occurrences))
+	}
+	if got := occurrences[0].From.Format("15:04"); got != "08:48" {
+		t.Errorf("expected the morning image at 08:48, got %s", got)
+	}
+	if got := occurrences[2].Window(); got < 50*time.Minute || got > time.Hour {
+		t.Errorf("expected the transition from sunset to civil dusk to last for about an hour, got %s", got)
+	}
+	if _, err := DataToSimple("solar.stw", []byte("stw: 1.0\n@sunrise: morning\n")); err == nil {
+		t.Error("expected an error when there is no latitude and longitude")
+	}
+	if reparsed, err := DataToSimple("solar.stw", []byte(stw.String())); err != nil || reparsed.String() != stw.String() {
+		t.Errorf("expected the solar times to be kept when writing and parsing, got:\n%s", reparsed)
+	}
+}
+
+func TestSolarSTWOrder(t *testing.T) {
+	stw, err := DataToSimple("solar.stw", []byte("stw: 1.2\ntz: Europe/Oslo\nlatitude: 59.91\nlongitude: 10.75\nformat: %s.jpg\n@weekend 09:00: weekend\n@civil-dusk: night\n@sunset .. civil-dusk: day .. night\n@noon: day\n@sunrise-30m: morning\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@sunrise-30m: morning\n@noon: day\n@sunset .. civil-dusk: day .. night\n@civil-dusk: night\n@weekend 09:00: weekend"
+	if s := stw.String(); !strings.HasSuffix(s, expected) {
+		t.Errorf("expected the events in the order of the day, with the weekend event last, got:\n%s", s)
+	}
+}
+
+func TestSolarSTWHighLatitude(t *testing.T) {
+	// The sun never gets 18 degrees below the horizon at the solar reference date in Longyearbyen
+	data := []byte("stw: 1.2\nname: svalbard\ntz: UTC\nlatitude: 78.2\nlongitude: 15.6\n@00:00: day\n@astronomical-dusk: night\n")
+	stw, err := DataToSimple("svalbard.stw", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stw.Statics) != 2 {
+		t.Fatalf("expected the astronomical dusk to be kept, got %d static images", len(stw.Statics))
+	}
+	s := stw.String()
+	if !strings.HasPrefix(s, "stw: 1.2\n") || !strings.Contains(s, "@astronomical-dusk: night") {
+		t.Errorf("expected the astronomical dusk to be written, got:\n%s", s)
+	}
+	if reparsed, err := DataToSimple("svalbard.stw", []byte(s)); err != nil || reparsed.String() != s {
+		t.Errorf("expected the astronomical dusk to be kept when writing and parsing, got:\n%s", reparsed)
+	}
+	// The event is only skipped on the days where it does not happen
+	for _, day := range []time.Time{time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 21, 0, 0, 0, 0, time.UTC)} {
+		onDate, err := stw.OnDate(day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := 2
+		if day.Month() == time.June {
+			expected = 1
+		}
+		if len(onDate.Statics) != expected {
+			t.Errorf("%s: expected %d static images, got %d", day.Format("2006-01-02"), expected, len(onDate.Statics))
+		}
+	}
+	if len(stw.Statics) != 2 {
+		t.Error("expected OnDate to leave the events of the timed wallpaper as they are")
+	}
+}
+
+func TestSunElevation(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2026, 6, 21, 0, 0, 0, 0, oslo)
+	noon := SolarNoon(date, 10.75)
+	if e := SunElevation(noon, 59.91, 10.75); e < 53 || e > 54 {
+		t.Errorf("expected the sun to be at about 53.5° at noon, got %f", e)
+	}
+	sunrise, _ := Sunrise(date, 59.91, 10.75)
+	if e := SunElevation(sunrise, 59.91, 10.75); e < -1.5 || e > 0 {
+		t.Errorf("expected the sun to be at about -0.8° at sunrise, got %f", e)
+	}
+
+	stw, err := DataToSimple("elevation.stw", []byte("stw: 1.0\ntz: Europe/Oslo\nlatitude: 59.91\nlongitude: 10.75\n@civil-dawn .. sunrise+2h: night .. day | overlay elevation(-6, 10)\n@sunset: night\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := stw.Transitions[0]
+	if tr.Elevation == nil || tr.Elevation.String() != "elevation(-6,10)" {
+		t.Fatalf("expected an elevation range from -6° to 10°, got %v", tr.Elevation)
+	}
+	winter := time.Date(2026, 12, 21, 0, 0, 0, 0, oslo)
+	o := &Occurrence{Event: tr}
+	o.From, _ = SolarEventTime(CivilDawnEvent, winter, 59.91, 10.75)
+	o.UpTo = o.From.Add(3 * time.Hour)
+	sunrise, _ = Sunrise(winter, 59.91, 10.75)
+	// At sunrise, the sun is 5.2° above -6°, which is about a third of the way to 10°
+	if ratio := stw.progress(o, sunrise); ratio < 0.3 || ratio > 0.4 {
+		t.Errorf("expected the transition to be about a third complete at sunrise, got %f", ratio)
+	}
+	if ratio := stw.progress(o, o.From.Add(-time.Hour)); ratio != 0 {
+		t.Errorf("expected the transition to not have started before civil dawn, got %f", ratio)
+	}
+	if !strings.Contains(stw.String(), "| overlay elevation(-6,10)") || !strings.HasPrefix(stw.String(), "stw: 1.2") {
+		t.Errorf("expected the elevation range to be written as version 1.2, got:\n%s", stw)
+	}
+	if _, err := DataToSimple("elevation.stw", []byte("stw: 1.0\n@06:00-08:00: night .. day | overlay elevation(-6,10)\n")); err == nil {
+		t.Error("expected an error when there is no latitude and longitude")
+	}
+	if _, err := DataToSimple("elevation.stw", []byte("stw: 1.0\n@06:00-08:00: night .. day | overlay sideways\n")); err == nil {
+		t.Error("expected an error for an unknown transition option")
+	}
+}
@@ -2,22 +2,42 @@ package timed
 
 import (
 	"fmt"
-	"strings"
 	"time"
 )
 
 type Static struct {
-	At       time.Time
-	Filename string
+	At        time.Time
+	Filename  string
+	Solar     *SolarTime // set if At is relative to a solar event
+	Condition *Condition // set if the event only applies to some days
+	Cron      *CronSpec  // set if the image is shown according to a cron expression
+	Source    string     // the included file that the event is from, or "" for the file itself
 }
 
+// timestamp returns when the static image event starts, as written in
+// Simple Timed Wallpaper files
+func (s *Static) timestamp() string {
+	at := cFmt(s.At)
+	if s.Solar != nil {
+		at = s.Solar.String()
+	}
+	if s.Cron != nil {
+		at = "cron " + s.Cron.String()
+	}
+	if s.Condition != nil {
+		return s.Condition.String() + " " + at
+	}
+	return at
+}
+
+// String returns the static image event as it is written in Simple Timed
+// Wallpaper files, where the filename is reduced with the given format
+// string, if possible. The format string may use "%s" or "{}".
 func (s *Static) String(format string) string {
-	if !strings.Contains(format, "%s") {
-		// Return the verbose version, where type is always included and the filename is not reduced with a common string format
-		return fmt.Sprintf("@%s: %s", cFmt(s.At), s.Filename)
+	name, ok := unapplyFormat(format, s.Filename)
+	if !ok {
+		// Return the verbose version, where the filename is not reduced with a common string format
+		name = s.Filename
 	}
-	fields := strings.SplitN(format, "%s", 2)
-	prefix := fields[0]
-	suffix := fields[1]
-	return fmt.Sprintf("@%s: %s", cFmt(s.At), s.Filename[len(prefix):len(s.Filename)-len(suffix)])
+	return fmt.Sprintf("@%s: %s", s.timestamp(), name)
 }
@@ -0,0 +1,148 @@
+package timed
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TimeMapping maps the time range From up to UpTo onto the time range
+// NewFrom up to NewUpTo. Only the clock time of the timestamps is used.
+type TimeMapping struct {
+	From    time.Time
+	UpTo    time.Time
+	NewFrom time.Time
+	NewUpTo time.Time
+}
+
+// breakpoint is a point on the 24h clock, together with where it should be moved
+type breakpoint struct {
+	at time.Duration
+	to time.Duration
+}
+
+// transformPrecision is what transformed event times are rounded to, since
+// the Simple Timed Wallpaper format has second precision
+const transformPrecision = time.Second
+
+// Shift returns a new Simple Timed Wallpaper where every event has been
+// moved by the given duration. Events that are moved past midnight wrap
+// around to the start of the day, and the other way around for negative
+// durations.
+func (fw *FatWallpaper) Shift(d time.Duration) (*FatWallpaper, error) {
+	return fw.transform(func(t time.Time) time.Time {
+		return clockTime(sinceMidnight(t) + d)
+	})
+}
+
+// Scale returns a new Simple Timed Wallpaper where the time range from..upTo
+// is linearly scaled to fit newFrom..newUpTo. The rest of the day is scaled
+// to fit in the remaining time. For example, 07:00..19:00 can be mapped onto
+// 09:00..16:00, for a short winter daylight period.
+func (fw *FatWallpaper) Scale(from, upTo, newFrom, newUpTo time.Time) (*FatWallpaper, error) {
+	return fw.Remap([]*TimeMapping{{From: from, UpTo: upTo, NewFrom: newFrom, NewUpTo: newUpTo}})
+}
+
+// Remap returns a new Simple Timed Wallpaper where the time ranges in the
+// given mappings are moved and scaled piecewise linearly. The time between
+// the given ranges is stretched to fill the time that is left. The mappings
+// must keep the events in the same order around the clock.
+func (fw *FatWallpaper) Remap(mappings []*TimeMapping) (*FatWallpaper, error) {
+	if len(mappings) == 0 {
+		return nil, errors.New("no time mappings given")
+	}
+	var points []breakpoint
+	add := func(at, to time.Time) error {
+		p := breakpoint{sinceMidnight(at), sinceMidnight(to)}
+		for _, existing := range points {
+			if existing.at == p.at {
+				if existing.to != p.to {
+					return fmt.Errorf("%s is mapped to both %s and %s", cFmt(at), cFmt(clockTime(existing.to)), cFmt(to))
+				}
+				return nil
+			}
+		}
+		points = append(points, p)
+		return nil
+	}
+	for _, m := range mappings {
+		if err := add(m.From, m.NewFrom); err != nil {
+			return nil, err
+		}
+		if err := add(m.UpTo, m.NewUpTo); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].at < points[j].at
+	})
+
+	// The new times must go once around the clock, in the same order as the old times
+	if len(points) > 1 {
+		var total time.Duration
+		for i, p := range points {
+			total += mod24(points[(i+1)%len(points)].to - p.to)
+		}
+		if total != h24 {
+			return nil, errors.New("the time mappings does not keep the events in order")
+		}
+	}
+
+	return fw.transform(func(t time.Time) time.Time {
+		return clockTime(remap(points, sinceMidnight(t)))
+	})
+}
+
+// remap moves the given time of day, by interpolating linearly between the
+// two breakpoints that surround it
+func remap(points []breakpoint, at time.Duration) time.Duration {
+	if len(points) == 1 {
+		return at + points[0].to - points[0].at
+	}
+	// Find the last breakpoint before the given time, wrapping around midnight
+	i := len(points) - 1
+	for j, p := range points {
+		if p.at <= at {
+			i = j
+		}
+	}
+	a := points[i]
+	b := points[(i+1)%len(points)]
+	span := mod24(b.at - a.at)
+	newSpan := mod24(b.to - a.to)
+	elapsed := mod24(at - a.at)
+	return a.to + time.Duration(float64(elapsed)*float64(newSpan)/float64(span))
+}
+
+// transform returns a new Simple Timed Wallpaper where the time of every
+// event has been moved with the given function. The start and end time of
+// transitions are both moved, so that transitions are stretched along with
+// the rest of the day. The new timed wallpaper is validated before it is
+// returned.
+func (fw *FatWallpaper) transform(f func(time.Time) time.Time) (*FatWallpaper, error) {
+	stw, err := fw.toSimple()
+	if err != nil {
+		return nil, err
+	}
+	if stw.usesSolarTimes() {
+		return nil, errors.New("can not move events that are relative to solar events")
+	}
+	if stw.usesCron() {
+		return nil, errors.New("can not move events that are shown according to cron expressions")
+	}
+	move := func(t time.Time) time.Time {
+		return clockTime(sinceMidnight(f(t)).Round(transformPrecision))
+	}
+	for _, s := range stw.Statics {
+		s.At = move(s.At)
+	}
+	for _, t := range stw.Transitions {
+		t.From = move(t.From)
+		t.UpTo = move(t.UpTo)
+	}
+	if err := stw.Validate(); err != nil {
+		return nil, fmt.Errorf("transformed timed wallpaper is invalid: %s", err)
+	}
+	return stw, nil
+}
@@ -0,0 +1,61 @@
+package timed
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleFatWallpaper_Scale() {
+	stw, err := ParseSTW("testdata/adwaita-timed2.stw")
+	if err != nil {
+		panic(err)
+	}
+	at := func(s string) time.Time {
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	winter, err := stw.Scale(at("07:00"), at("19:00"), at("09:00"), at("16:00"))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(winter)
+
+	// Output:
+	// stw: 1.0
+	// name: adwaita-timed
+	// format: /usr/share/backgrounds/gnome/adwaita-%s.jpg
+	// @06:10-09:00: night .. morning
+	// @09:00: morning
+	// @09:35-12:30: morning .. day
+	// @12:30: day
+	// @15:25-23:05: day .. night
+	// @23:05: night
+}
+
+func TestShift(t *testing.T) {
+	stw, err := ParseSTW("testdata/adwaita-timed2.stw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shifted, err := stw.Shift(-8 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cFmt(shifted.Statics[0].At); got != "23:00" {
+		t.Errorf("expected the 07:00 event to be shifted to 23:00, got %s", got)
+	}
+	if got := shifted.Transitions[1].Duration(); got != 6*time.Hour {
+		t.Errorf("expected the day .. night transition to last 6h, got %s", got)
+	}
+	back, err := shifted.Shift(8 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != stw.String() {
+		t.Errorf("shifting back and forth should give the same timed wallpaper, got:\n%s", back)
+	}
+}
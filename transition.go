@@ -1,6 +1,7 @@
 package timed
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -12,25 +13,137 @@ type Transition struct {
 	FromFilename string
 	ToFilename   string
 	Type         string
+	FromSolar    *SolarTime      // set if From is relative to a solar event
+	UpToSolar    *SolarTime      // set if UpTo is relative to a solar event
+	Elevation    *ElevationRange // set if the progress follows the elevation of the sun
+	Easing       *Easing         // set if the progress follows an easing curve
+	ColorSpace   string          // the color space that overlay transitions crossfade in, or "" for linear light
+	Condition    *Condition      // set if the event only applies to some days
+	Source       string          // the included file that the event is from, or "" for the file itself
+}
+
+// splitOptions splits the options after "|" in a transition line at
+// whitespace, except within parentheses
+func splitOptions(s string) []string {
+	var (
+		options []string
+		depth   int
+		start   = -1
+	)
+	for i, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case (r == ' ' || r == '\t') && depth == 0:
+			if start != -1 {
+				options = append(options, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	if start != -1 {
+		options = append(options, s[start:])
+	}
+	return options
+}
+
+// parseOptions parses what comes after "|" in a transition line. The first
+// word is the transition type, and the rest are options, like the
+// elevation range "elevation(-6,10)" or the easing curve "smoothstep".
+func (t *Transition) parseOptions(s string) error {
+	options := splitOptions(s)
+	if len(options) == 0 {
+		return errors.New("missing transition type")
+	}
+	t.Type = options[0]
+	if err := checkTransitionType(t.Type); err != nil {
+		return err
+	}
+	for _, option := range options[1:] {
+		switch {
+		case strings.HasPrefix(option, "elevation("):
+			er, err := ParseElevationRange(option)
+			if err != nil {
+				return err
+			}
+			t.Elevation = er
+		case isEasing(option):
+			e, err := ParseEasing(option)
+			if err != nil {
+				return err
+			}
+			t.Easing = e
+		case isColorSpace(option):
+			if t.Type != "overlay" {
+				return fmt.Errorf("only overlay transitions can have a color space: %s", option)
+			}
+			t.ColorSpace = option
+		default:
+			return fmt.Errorf("unknown transition option: %s", option)
+		}
+	}
+	return nil
+}
+
+// options returns the transition type and options, as written after "|"
+// in Simple Timed Wallpaper files
+func (t *Transition) options() string {
+	options := t.Type
+	if t.Elevation != nil {
+		options += " " + t.Elevation.String()
+	}
+	if t.Easing != nil {
+		options += " " + t.Easing.String()
+	}
+	if t.ColorSpace != "" {
+		options += " " + t.ColorSpace
+	}
+	return options
+}
+
+// timestamps returns when the transition starts and ends, as written in
+// Simple Timed Wallpaper files
+func (t *Transition) timestamps() string {
+	prefix := ""
+	if t.Condition != nil {
+		prefix = t.Condition.String() + " "
+	}
+	if t.FromSolar == nil && t.UpToSolar == nil {
+		return prefix + cFmt(t.From) + "-" + cFmt(t.UpTo)
+	}
+	from := cFmt(t.From)
+	if t.FromSolar != nil {
+		from = t.FromSolar.String()
+	}
+	upTo := cFmt(t.UpTo)
+	if t.UpToSolar != nil {
+		upTo = t.UpToSolar.String()
+	}
+	return prefix + from + " .. " + upTo
 }
 
 func (t *Transition) Duration() time.Duration {
 	return mod24(t.UpTo.Sub(t.From))
 }
 
+// String returns the transition as it is written in Simple Timed Wallpaper
+// files, where the filenames are reduced with the given format string, if
+// possible. The format string may use "%s" or "{}".
 func (t *Transition) String(format string) string {
-	if !strings.Contains(format, "%s") {
-		// Return the verbose version, where type is always included and the filename is not reduced with a common string format
-		if t.Type == "overlay" {
-			return fmt.Sprintf("@%s-%s: %s .. %s", cFmt(t.From), cFmt(t.UpTo), t.FromFilename, t.ToFilename)
-		}
-		return fmt.Sprintf("@%s-%s: %s .. %s | %s", cFmt(t.From), cFmt(t.UpTo), t.FromFilename, t.ToFilename, t.Type)
+	fromName, ok1 := unapplyFormat(format, t.FromFilename)
+	toName, ok2 := unapplyFormat(format, t.ToFilename)
+	if !ok1 || !ok2 {
+		// Use the verbose version, where the filenames are not reduced with a common string format
+		fromName, toName = t.FromFilename, t.ToFilename
 	}
-	fields := strings.SplitN(format, "%s", 2)
-	prefix := fields[0]
-	suffix := fields[1]
-	if t.Type == "overlay" {
-		return fmt.Sprintf("@%s-%s: %s .. %s", cFmt(t.From), cFmt(t.UpTo), t.FromFilename[len(prefix):len(t.FromFilename)-len(suffix)], t.ToFilename[len(prefix):len(t.ToFilename)-len(suffix)])
+	if t.options() == "overlay" {
+		return fmt.Sprintf("@%s: %s .. %s", t.timestamps(), fromName, toName)
 	}
-	return fmt.Sprintf("@%s-%s: %s .. %s | %s", cFmt(t.From), cFmt(t.UpTo), t.FromFilename[len(prefix):len(t.FromFilename)-len(suffix)], t.ToFilename[len(prefix):len(t.ToFilename)-len(suffix)], t.Type)
+	return fmt.Sprintf("@%s: %s .. %s | %s", t.timestamps(), fromName, toName, t.options())
 }
@@ -0,0 +1,131 @@
+package timed
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// TransitionFunc renders a frame of a transition between two images, where
+// the ratio goes from 0, for only the "from" image, to 1, for only the "to"
+// image. The two images are shared with DefaultImageCache and must not be
+// modified, so a new image must be returned instead.
+type TransitionFunc func(from, to image.Image, ratio float64) image.Image
+
+var (
+	transitionMut   = &sync.RWMutex{}
+	customOverlay   bool // if "overlay" has been replaced by RegisterTransition
+	transitionFuncs = map[string]TransitionFunc{
+		"overlay": func(from, to image.Image, ratio float64) image.Image {
+			return Crossfade(from, to, ratio, LinearLight)
+		},
+	}
+)
+
+// RegisterTransition makes a transition type available, so that it can be
+// used after "|" in Simple Timed Wallpaper files and in the type attribute
+// of GNOME timed wallpapers. An already registered transition type with the
+// same name is replaced. The images that are given to f must not be
+// modified. Panics if the name is empty or if f is nil.
+func RegisterTransition(name string, f TransitionFunc) {
+	if name == "" || f == nil {
+		panic("timed: RegisterTransition needs a name and a function")
+	}
+	transitionMut.Lock()
+	defer transitionMut.Unlock()
+	transitionFuncs[name] = f
+	if name == "overlay" {
+		customOverlay = true
+	}
+}
+
+// LookupTransition returns the function for the given transition type
+func LookupTransition(name string) (TransitionFunc, bool) {
+	transitionMut.RLock()
+	defer transitionMut.RUnlock()
+	f, ok := transitionFuncs[name]
+	return f, ok
+}
+
+// TransitionTypes returns the names of the registered transition types, sorted
+func TransitionTypes() []string {
+	transitionMut.RLock()
+	defer transitionMut.RUnlock()
+	var names []string
+	for name := range transitionFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkTransitionType returns an error if the given transition type is not registered
+func checkTransitionType(name string) error {
+	if _, ok := LookupTransition(name); !ok {
+		return fmt.Errorf("unknown transition type: %s", name)
+	}
+	return nil
+}
+
+// Render renders a frame of the transition, from the two given images,
+// using the function that is registered for the transition type. Overlay
+// transitions with a color space are crossfaded in that color space. The
+// ratio is clamped to 0 to 1 first, since easing curves may overshoot.
+func (t *Transition) Render(from, to image.Image, ratio float64) (image.Image, error) {
+	ratio = math.Max(0, math.Min(1, ratio))
+	if t.Type == "overlay" && t.ColorSpace != "" {
+		return Crossfade(from, to, ratio, t.ColorSpace), nil
+	}
+	f, ok := LookupTransition(t.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown transition type: %s", t.Type)
+	}
+	return f(from, to, ratio), nil
+}
+
+// renderFrame opens the two images of the transition, through the default
+// image cache, and renders a frame
+func (t *Transition) renderFrame(ratio float64) (image.Image, error) {
+	tFromImg, err := DefaultImageCache.Open(t.FromFilename)
+	if err != nil {
+		return nil, err
+	}
+	tToImg, err := DefaultImageCache.Open(t.ToFilename)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(tFromImg, tToImg, ratio)
+}
+
+// writeFrame opens the two images of the transition, through the default
+// image cache, and encodes a frame to w in the given frame format. Overlay
+// transitions are crossfaded by the default blender.
+func (t *Transition) writeFrame(w io.Writer, ratio float64, ff *FrameFormat, dither string) error {
+	ratio = math.Max(0, math.Min(1, ratio))
+	tFromImg, err := DefaultImageCache.Open(t.FromFilename)
+	if err != nil {
+		return err
+	}
+	tToImg, err := DefaultImageCache.Open(t.ToFilename)
+	if err != nil {
+		return err
+	}
+	transitionMut.RLock()
+	blended := t.Type == "overlay" && (t.ColorSpace != "" || !customOverlay)
+	transitionMut.RUnlock()
+	if blended {
+		colorSpace := t.ColorSpace
+		if colorSpace == "" {
+			colorSpace = LinearLight
+		}
+		return DefaultBlender.Encode(w, tFromImg, tToImg, ratio, colorSpace, dither, ff)
+	}
+	img, err := t.Render(tFromImg, tToImg, ratio)
+	if err != nil {
+		return err
+	}
+	return ff.Encoder()(w, Dither(img, dither))
+}
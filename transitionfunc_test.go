@@ -0,0 +1,135 @@
+package timed
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+// uniform returns a small image with only the given gray level
+func uniform(gray uint8) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.RGBA{gray, gray, gray, 255})
+		}
+	}
+	return img
+}
+
+func TestRegisterTransition(t *testing.T) {
+	// Transition types that are not registered are errors when parsing
+	data := []byte("stw: 1.0\n@00:00-06:00: night .. day | cut\n@06:00: day\n")
+	if _, err := DataToSimple("cut.stw", data); err == nil || !strings.Contains(err.Error(), "unknown transition type: cut") {
+		t.Errorf("expected an unknown transition type error, got %v", err)
+	}
+
+	// And when validating
+	stw := NewSimple("1.0", "cut", "")
+	stw.AddTransition(clockTime(0), clockTime(6*time.Hour), "night", "day", "cut")
+	if err := stw.Validate(); err == nil || !strings.Contains(err.Error(), "unknown transition type: cut") {
+		t.Errorf("expected an unknown transition type error, got %v", err)
+	}
+
+	RegisterTransition("cut", func(from, to image.Image, ratio float64) image.Image {
+		if ratio < 0.5 {
+			return from
+		}
+		return to
+	})
+	defer func() {
+		// Do not leave the transition type registered for the other tests
+		transitionMut.Lock()
+		delete(transitionFuncs, "cut")
+		transitionMut.Unlock()
+	}()
+	if _, err := DataToSimple("cut.stw", data); err != nil {
+		t.Error(err)
+	}
+	if err := stw.Validate(); err != nil {
+		t.Error(err)
+	}
+	img, err := stw.Transitions[0].Render(uniform(0), uniform(255), 0.75)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Errorf("expected the registered transition to be used, got %d", r)
+	}
+
+	// The overlay transition crossfades
+	overlay := &Transition{Type: "overlay", ColorSpace: SRGB}
+	img, err = overlay.Render(uniform(0), uniform(200), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r>>8 < 90 || r>>8 > 110 {
+		t.Errorf("expected a crossfaded gray level, got %d", r>>8)
+	}
+}
+
+// countGray counts the pixels in the image that have the given gray level
+func countGray(img image.Image, gray uint8) int {
+	count := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); uint8(r>>8) == gray {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestBuiltinTransitions(t *testing.T) {
+	from, to := image.NewRGBA(image.Rect(0, 0, 48, 48)), image.NewRGBA(image.Rect(0, 0, 48, 48))
+	for i := range to.Pix {
+		to.Pix[i] = 255
+	}
+	for _, name := range []string{"wipe-left", "wipe-right", "wipe-up", "wipe-down", "slide-left", "slide-right", "slide-up", "slide-down", "dissolve", "radial", "blinds"} {
+		tr := &Transition{Type: name}
+		for _, ratio := range []float64{0, 0.5, 1} {
+			img, err := tr.Render(from, to, ratio)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shown := float64(countGray(img, 255)) / (48 * 48)
+			if ratio != 0.5 && shown != ratio {
+				t.Errorf("%s at %v: expected %v of the to image, got %v", name, ratio, ratio, shown)
+			}
+			if ratio == 0.5 && (shown < 0.3 || shown > 0.7) {
+				t.Errorf("%s at %v: expected about half of the to image, got %v", name, ratio, shown)
+			}
+		}
+	}
+
+	// Ratios outside of 0 to 1 show only one of the images, instead of panicking
+	for _, name := range TransitionTypes() {
+		f, _ := LookupTransition(name)
+		for ratio, expected := range map[float64]float64{-0.5: 0, 1.5: 1, 2: 1} {
+			if shown := float64(countGray(f(from, to, ratio), 255)) / (48 * 48); shown != expected {
+				t.Errorf("%s at %v: expected %v of the to image, got %v", name, ratio, expected, shown)
+			}
+		}
+	}
+
+	// The wipes and the slides move in the direction they are named after
+	img, _ := (&Transition{Type: "wipe-right"}).Render(from, to, 0.5)
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Error("expected wipe-right to start showing the to image on the left")
+	}
+	img, _ = (&Transition{Type: "slide-left"}).Render(from, to, 0.5)
+	if r, _, _, _ := img.At(47, 0).RGBA(); r != 0xffff {
+		t.Error("expected slide-left to bring in the to image from the right")
+	}
+
+	// Dissolve is the same every time
+	a, _ := (&Transition{Type: "dissolve"}).Render(from, to, 0.4)
+	b, _ := (&Transition{Type: "dissolve"}).Render(from, to, 0.4)
+	if string(a.(*image.RGBA).Pix) != string(b.(*image.RGBA).Pix) {
+		t.Error("expected dissolve to be deterministic")
+	}
+}
@@ -0,0 +1,119 @@
+package timed
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// The built-in transition types, in addition to "overlay". The wipes and
+// the slides are named after the direction that the edge between the two
+// images moves in.
+func init() {
+	RegisterTransition("wipe-left", wipe(func(x, y, w, h int, ratio float64) bool { return float64(x) >= float64(w)*(1-ratio) }))
+	RegisterTransition("wipe-right", wipe(func(x, y, w, h int, ratio float64) bool { return float64(x) < float64(w)*ratio }))
+	RegisterTransition("wipe-up", wipe(func(x, y, w, h int, ratio float64) bool { return float64(y) >= float64(h)*(1-ratio) }))
+	RegisterTransition("wipe-down", wipe(func(x, y, w, h int, ratio float64) bool { return float64(y) < float64(h)*ratio }))
+	RegisterTransition("slide-left", slide(-1, 0))
+	RegisterTransition("slide-right", slide(1, 0))
+	RegisterTransition("slide-up", slide(0, -1))
+	RegisterTransition("slide-down", slide(0, 1))
+	RegisterTransition("dissolve", wipe(func(x, y, w, h int, ratio float64) bool { return noise(x, y) < ratio }))
+	RegisterTransition("radial", wipe(radial))
+	RegisterTransition("blinds", wipe(blinds))
+}
+
+// blindsCount is how many slats the "blinds" transition has
+const blindsCount = 12
+
+// transitionCanvas returns copies of the two images, where both have the
+// size of the "from" image and start at (0, 0)
+func transitionCanvas(from, to image.Image) (*image.RGBA, *image.RGBA) {
+	fb := from.Bounds()
+	rect := image.Rect(0, 0, fb.Dx(), fb.Dy())
+	a := image.NewRGBA(rect)
+	draw.Draw(a, rect, from, fb.Min, draw.Src)
+	b := image.NewRGBA(rect)
+	draw.Draw(b, rect, to, to.Bounds().Min, draw.Src)
+	return a, b
+}
+
+// copyPixel copies a pixel from one RGBA image to another
+func copyPixel(dst *image.RGBA, x, y int, src *image.RGBA, sx, sy int) {
+	di := dst.PixOffset(x, y)
+	si := src.PixOffset(sx, sy)
+	copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+}
+
+// wipe returns a transition that shows the "to" image where the given
+// function returns true, and the "from" image elsewhere
+func wipe(showTo func(x, y, w, h int, ratio float64) bool) TransitionFunc {
+	return func(from, to image.Image, ratio float64) image.Image {
+		a, b := transitionCanvas(from, to)
+		w, h := a.Rect.Dx(), a.Rect.Dy()
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if showTo(x, y, w, h, ratio) {
+					copyPixel(a, x, y, b, x, y)
+				}
+			}
+		}
+		return a
+	}
+}
+
+// slide returns a transition where the "to" image pushes the "from" image
+// out, moving in the given direction. Ratios outside of 0 to 1 are clamped,
+// since the images would otherwise be read outside of their bounds.
+func slide(dx, dy int) TransitionFunc {
+	return func(from, to image.Image, ratio float64) image.Image {
+		ratio = math.Max(0, math.Min(1, ratio))
+		a, b := transitionCanvas(from, to)
+		w, h := a.Rect.Dx(), a.Rect.Dy()
+		offsetX := int(math.Round(float64(dx*w) * ratio))
+		offsetY := int(math.Round(float64(dy*h) * ratio))
+		result := image.NewRGBA(a.Rect)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				// The "from" image is moved by the offset, and the "to" image
+				// follows right behind it
+				sx, sy := x-offsetX, y-offsetY
+				if sx >= 0 && sx < w && sy >= 0 && sy < h {
+					copyPixel(result, x, y, a, sx, sy)
+				} else {
+					copyPixel(result, x, y, b, sx+dx*w, sy+dy*h)
+				}
+			}
+		}
+		return result
+	}
+}
+
+// noise returns a number from 0 up to 1 for the given pixel, which is the
+// same every time, so that the frames of the "dissolve" transition are the
+// same across restarts
+func noise(x, y int) float64 {
+	h := uint32(x)*0x9E3779B1 ^ uint32(y)*0x85EBCA77
+	h ^= h >> 16
+	h *= 0x7FEB352D
+	h ^= h >> 15
+	h *= 0x846CA68B
+	h ^= h >> 16
+	return float64(h) / (1 << 32)
+}
+
+// radial shows the "to" image within a circle that grows from the center
+func radial(x, y, w, h int, ratio float64) bool {
+	cx, cy := float64(w)/2, float64(h)/2
+	dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+	return math.Sqrt(dx*dx+dy*dy) < ratio*math.Sqrt(cx*cx+cy*cy)
+}
+
+// blinds shows the "to" image in horizontal slats that grow downwards
+func blinds(x, y, w, h int, ratio float64) bool {
+	slat := float64(h) / blindsCount
+	if slat <= 0 {
+		return ratio >= 1
+	}
+	return math.Mod(float64(y), slat) < slat*ratio
+}
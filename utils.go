@@ -16,16 +16,71 @@ var h24 = time.Hour * 24
 func mod24(d time.Duration) time.Duration {
 	hourDiff := d % h24
 	if hourDiff < 0 {
-		return -hourDiff
+		return hourDiff + h24
 	}
 	return hourDiff
 }
 
-// cFmt formats a timestamp as HH:MM
+// sinceMidnight returns how far into the day the clock time of t is
+func sinceMidnight(t time.Time) time.Duration {
+	hour, min, sec := t.Clock()
+	return time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+}
+
+// clockTime returns a timestamp of the same kind as the ones that are parsed
+// from Simple Timed Wallpaper files, for the given duration since midnight.
+// Durations outside of the 24h interval are wrapped.
+func clockTime(d time.Duration) time.Time {
+	return time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(mod24(d))
+}
+
+// cFmt formats a timestamp as HH:MM, or as HH:MM:SS if the seconds are not zero
 func cFmt(t time.Time) string {
+	if t.Second() != 0 {
+		return fmt.Sprintf("%.2d:%.2d:%.2d", t.Hour(), t.Minute(), t.Second())
+	}
 	return fmt.Sprintf("%.2d:%.2d", t.Hour(), t.Minute())
 }
 
+// isDigit checks if the given byte is an ASCII digit
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// clockLength returns the length of the HH:MM or HH:MM:SS timestamp at the
+// start of the given string, or 0 if there is no timestamp. The seconds are
+// only counted if the timestamp is followed by a colon or a dash, since
+// "@08:00:30" is the filename "30" at 08:00 in version 1.0 of the format.
+func clockLength(s string) int {
+	if len(s) < 5 || !isDigit(s[0]) || !isDigit(s[1]) || s[2] != ':' || !isDigit(s[3]) || !isDigit(s[4]) {
+		return 0
+	}
+	if len(s) >= 8 && s[5] == ':' && isDigit(s[6]) && isDigit(s[7]) {
+		rest := strings.TrimSpace(s[8:])
+		if strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "-") {
+			return 8
+		}
+	}
+	return 5
+}
+
+// parseClock parses a timestamp on the form HH:MM or HH:MM:SS
+func parseClock(s string) (time.Time, error) {
+	if len(s) > 5 {
+		return time.Parse("15:04:05", s)
+	}
+	return time.Parse("15:04", s)
+}
+
+// newerVersion checks if the version string a is newer than b, where both
+// are on the form major.minor
+func newerVersion(a, b string) bool {
+	var aMajor, aMinor, bMajor, bMinor int
+	fmt.Sscanf(a, "%d.%d", &aMajor, &aMinor)
+	fmt.Sscanf(b, "%d.%d", &bMajor, &bMinor)
+	return aMajor > bMajor || (aMajor == bMajor && aMinor > bMinor)
+}
+
 // dFmt formats a duration nicely
 func dFmt(d time.Duration) string {
 	s := fmt.Sprintf("%6s", d)
@@ -123,6 +178,31 @@ func CommonSuffix(sl []string) string {
 	return shortestString
 }
 
+// commonFormat finds a format string with a %s marker that can be used
+// for all of the given filenames, or returns an empty string. The prefix
+// ends with a "/", "-" or "_", and the suffix starts with a "/", "-", "_"
+// or ".", so that no filename is cut in the middle of a word, while shared
+// directories and extensions at the end are kept in the format.
+func commonFormat(filenames []string) string {
+	prefix := CommonPrefix(filenames)
+	prefix = prefix[:strings.LastIndexAny(prefix, "/-_")+1]
+	suffix := CommonSuffix(filenames)
+	if i := strings.IndexAny(suffix, "/-_."); i != -1 {
+		suffix = suffix[i:]
+	} else {
+		suffix = ""
+	}
+	if prefix == "" && suffix == "" {
+		return ""
+	}
+	for _, filename := range filenames {
+		if len(filename) <= len(prefix)+len(suffix) {
+			return ""
+		}
+	}
+	return prefix + "%s" + suffix
+}
+
 // Meat returns the meat of the string: the part that is after the prefix and
 // before the suffix. Will return the given string if it is too short to
 // contain the prefix and suffix.
@@ -132,3 +212,15 @@ func Meat(s, prefix, suffix string) string {
 	}
 	return s[len(prefix) : len(s)-len(suffix)]
 }
+
+// copyStringMap returns a copy of the given map, or nil if it is empty
+func copyStringMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for key, value := range m {
+		c[key] = value
+	}
+	return c
+}
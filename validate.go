@@ -0,0 +1,93 @@
+package timed
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate checks that the timed wallpaper is consistent. It must have a
+// version, no two events may start at the same time, all filenames must be
+// given, every transition must last for a while and transitions may not
+// overlap with other events. Events that apply to different days are
+// checked separately. GNOME timed wallpapers are converted to the Simple
+// Timed Wallpaper format before being checked.
+func (fw *FatWallpaper) Validate() error {
+	if fw.GNOME {
+		if fw.Config == nil {
+			return errors.New("GNOME timed wallpaper has no configuration")
+		}
+		stw, err := GnomeToSimple(fw)
+		if err != nil {
+			return err
+		}
+		return stw.Validate()
+	}
+	if strings.TrimSpace(fw.Version) == "" {
+		return errors.New("missing stw version")
+	}
+	if len(fw.Statics) == 0 && len(fw.Transitions) == 0 {
+		return errors.New("no static images and no transitions")
+	}
+	for _, key := range fw.conditions() {
+		if err := fw.schedule(key).validateSchedule(); err != nil {
+			if key != "" {
+				return fmt.Errorf("%s, for the events on %s", err, key)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSchedule checks the events of a Simple Timed Wallpaper where all
+// events apply to the same days
+func (fw *FatWallpaper) validateSchedule() error {
+	// Check that no two events start at the same time
+	startTimes := make(map[time.Duration]string)
+	for _, s := range fw.Statics {
+		if strings.TrimSpace(s.Filename) == "" {
+			return fmt.Errorf("static event at %s has no filename", s.timestamp())
+		}
+		if s.Cron != nil {
+			// Static images that are shown according to cron expressions may
+			// start at the same time as other events, and then replace them
+			continue
+		}
+		at := sinceMidnight(s.At)
+		if other, ok := startTimes[at]; ok {
+			return fmt.Errorf("static event at %s starts at the same time as %s", cFmt(s.At), other)
+		}
+		startTimes[at] = "static event at " + cFmt(s.At)
+	}
+	for _, t := range fw.Transitions {
+		if strings.TrimSpace(t.FromFilename) == "" || strings.TrimSpace(t.ToFilename) == "" {
+			return fmt.Errorf("transition at %s-%s is missing a filename", cFmt(t.From), cFmt(t.UpTo))
+		}
+		if err := checkTransitionType(t.Type); err != nil {
+			return fmt.Errorf("transition at %s-%s: %s", cFmt(t.From), cFmt(t.UpTo), err)
+		}
+		if t.Duration() == 0 {
+			return fmt.Errorf("transition at %s-%s has no duration", cFmt(t.From), cFmt(t.UpTo))
+		}
+		from := sinceMidnight(t.From)
+		if other, ok := startTimes[from]; ok {
+			return fmt.Errorf("transition at %s-%s starts at the same time as %s", cFmt(t.From), cFmt(t.UpTo), other)
+		}
+		startTimes[from] = "transition at " + cFmt(t.From) + "-" + cFmt(t.UpTo)
+	}
+
+	// Check that no event starts within the window of a transition
+	for _, t := range fw.Transitions {
+		for at, other := range startTimes {
+			if at == sinceMidnight(t.From) {
+				continue
+			}
+			if mod24(at-sinceMidnight(t.From)) < t.Duration() {
+				return fmt.Errorf("%s is within the transition at %s-%s", other, cFmt(t.From), cFmt(t.UpTo))
+			}
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,15 +15,30 @@ var defaultEventLoopDelay = 5 * time.Second
 
 // FatWallpaper contains all data for either a Simple Timed Wallpaper or a GNOME Timed Wallpaper
 type FatWallpaper struct {
-	GNOME       bool
-	Version     string
-	Name        string
-	Format      string
-	Path        string // not part of the file data, but handy when parsing
-	Statics     []*Static
-	Transitions []*Transition
-	LoopWait    time.Duration // how long the main event loop should sleep
-	Config      *GBackground  // set to nil when not a GNOME timed wallpaper
+	GNOME        bool
+	Version      string
+	Name         string
+	Names        map[string]string // localized names, by locale, like "nb" or "pt_BR"
+	Format       string
+	Path         string // not part of the file data, but handy when parsing
+	Statics      []*Static
+	Transitions  []*Transition
+	LoopWait     time.Duration     // how long the main event loop should sleep
+	FrameFormat  *FrameFormat      // the format that blended frames are written in, or nil for DefaultFrameFormat
+	FrameCache   *FrameCache       // where transition frames are rendered ahead of time, or nil for rendering them when needed
+	Config       *GBackground      // set to nil when not a GNOME timed wallpaper
+	Location     *time.Location    // the time zone of the event times, or nil for the local time zone
+	Latitude     float64           // in degrees, north is positive, used for finding solar event times
+	Longitude    float64           // in degrees, east is positive, used for finding solar event times
+	HolidaysFile string            // the file that the holidays were read from, if any
+	Holidays     []*DateRange      // used for events that only apply to holidays
+	Hemisphere   string            // "north" or "south", or "" for finding it from the latitude
+	BlendDays    int               // for how many days the events for two seasons are blended, or 0
+	Includes     []*Include        // other Simple Timed Wallpaper files that the events are included from
+	Dither       string            // how blended frames are dithered when they are written, see Dither
+	Variables    map[string]string // from "set" lines, used in the format string and in the filenames
+	Metadata     map[string]string // like "author" and "license", see IsMetadataField
+	inherited    inheritedFields   // the header fields that were taken from included files
 }
 
 // NewGnome creates a new Gnome Timed Wallpaper struct
@@ -39,6 +55,34 @@ func NewSimple(version, name, format string) *FatWallpaper {
 	return &FatWallpaper{GNOME: false, Version: version, Name: name, Format: format, Path: "", Statics: statics, Transitions: transitions, LoopWait: defaultEventLoopDelay}
 }
 
+// Copy returns a copy of this timed wallpaper, where the static and
+// transition events are copied too, so that they can be modified freely
+func (fw *FatWallpaper) Copy() *FatWallpaper {
+	c := *fw
+	c.Names = copyStringMap(fw.Names)
+	c.Metadata = copyStringMap(fw.Metadata)
+	c.Statics = make([]*Static, len(fw.Statics))
+	for i, s := range fw.Statics {
+		sc := *s
+		c.Statics[i] = &sc
+	}
+	c.Transitions = make([]*Transition, len(fw.Transitions))
+	for i, t := range fw.Transitions {
+		tc := *t
+		c.Transitions[i] = &tc
+	}
+	return &c
+}
+
+// toSimple returns a copy of this timed wallpaper as a Simple Timed
+// Wallpaper, converting it first if it is a GNOME timed wallpaper
+func (fw *FatWallpaper) toSimple() (*FatWallpaper, error) {
+	if fw.GNOME {
+		return GnomeToSimple(fw)
+	}
+	return fw.Copy(), nil
+}
+
 // StartTime returns the timed wallpaper start time, as a time.Time
 func (fw *FatWallpaper) StartTime() time.Time {
 	if !fw.GNOME {
@@ -47,7 +91,7 @@ func (fw *FatWallpaper) StartTime() time.Time {
 	// gtw.Config.StartTime is a struct that contains ints,
 	// where the values are directly from the parsed XML.
 	st := fw.Config.StartTime
-	return time.Date(st.Year, time.Month(st.Month), st.Day, st.Hour, st.Minute, 0, 0, time.Local)
+	return time.Date(st.Year, time.Month(st.Month), st.Day, st.Hour, st.Minute, st.Second, 0, fw.location())
 }
 
 func (fw *FatWallpaper) Images() []string {
@@ -84,29 +128,156 @@ func (fw *FatWallpaper) String() string {
 		}
 		return strings.TrimSpace(sb.String())
 	} else {
-		var lines []string
-		for _, s := range fw.Statics {
-			lines = append(lines, s.String(fw.Format))
+		// Events with filenames that can not be written with the format
+		// string are written after an empty format field
+		var lines, otherLines []eventLine
+		format := fw.expandedFormat()
+		for _, e := range fw.events() {
+			if eventSource(e) != "" {
+				continue
+			}
+			var (
+				line    string
+				daily   bool
+				matches = matchesFormat(e, format)
+			)
+			switch v := e.(type) {
+			case *Static:
+				if matches {
+					line = v.String(format)
+				} else {
+					line = v.String("")
+				}
+				daily = v.Condition == nil && v.Cron == nil
+			case *Transition:
+				if matches {
+					line = v.String(format)
+				} else {
+					line = v.String("")
+				}
+				daily = v.Condition == nil
+			}
+			l := eventLine{line: line, daily: daily, start: sinceMidnight(eventStart(e))}
+			if matches {
+				lines = append(lines, l)
+			} else {
+				otherLines = append(otherLines, l)
+			}
 		}
-		for _, t := range fw.Transitions {
-			lines = append(lines, t.String(fw.Format))
+		var body []string
+		for _, l := range sortedEventLines(lines) {
+			body = append(body, l.line)
 		}
-		sort.Strings(lines)
-		return fmt.Sprintf("stw: %s\nname: %s\nformat: %s\n", fw.Version, fw.Name, fw.Format) + strings.Join(lines, "\n")
+		if len(otherLines) > 0 {
+			body = append(body, "format:")
+			for _, l := range sortedEventLines(otherLines) {
+				body = append(body, l.line)
+			}
+		}
+		header := fmt.Sprintf("stw: %s\nname: %s\n", fw.formatVersion(), fw.Name)
+		for _, line := range fw.nameLines() {
+			header += line + "\n"
+		}
+		for _, line := range fw.metadataLines() {
+			header += line + "\n"
+		}
+		for _, line := range fw.variableLines() {
+			header += line + "\n"
+		}
+		header += fmt.Sprintf("format: %s\n", fw.Format)
+		if fw.Location != nil && fw.Location != fw.inherited.location {
+			header += fmt.Sprintf("tz: %s\n", fw.Location)
+		}
+		if (fw.usesSunPosition() || fw.Latitude != 0 || fw.Longitude != 0) && !fw.inheritsCoordinates() {
+			header += fmt.Sprintf("latitude: %s\nlongitude: %s\n", strconv.FormatFloat(fw.Latitude, 'f', -1, 64), strconv.FormatFloat(fw.Longitude, 'f', -1, 64))
+		}
+		if fw.HolidaysFile != "" {
+			header += fmt.Sprintf("holidays: %s\n", fw.HolidaysFile)
+		}
+		if fw.Hemisphere != "" {
+			header += fmt.Sprintf("hemisphere: %s\n", fw.Hemisphere)
+		}
+		if fw.BlendDays > 0 {
+			header += fmt.Sprintf("blend-days: %d\n", fw.BlendDays)
+		}
+		if fw.Dither != "" {
+			header += fmt.Sprintf("dither: %s\n", fw.Dither)
+		}
+		for _, inc := range fw.Includes {
+			header += fmt.Sprintf("include: %s\n", inc)
+		}
+		return header + strings.Join(body, "\n")
 	}
 }
 
+// eventLine is an event as it is written in Simple Timed Wallpaper files
+type eventLine struct {
+	line  string
+	daily bool          // if the event applies to every day, without a condition or a cron expression
+	start time.Duration // when the event starts, where solar events start at the reference date
+}
+
+// sortedEventLines sorts event lines by when they start, with the events
+// that only apply to some days after the daily ones
+func sortedEventLines(lines []eventLine) []eventLine {
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		if a.daily != b.daily {
+			return a.daily
+		}
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		return a.line < b.line
+	})
+	return lines
+}
+
+// inheritsCoordinates checks if the latitude and longitude are the ones
+// that were taken from an included file
+func (fw *FatWallpaper) inheritsCoordinates() bool {
+	return fw.inherited.coordinates && fw.Latitude == fw.inherited.latitude && fw.Longitude == fw.inherited.longitude
+}
+
+// usesSeconds checks if any of the event timestamps has seconds
+func (fw *FatWallpaper) usesSeconds() bool {
+	for _, s := range fw.Statics {
+		if s.Solar == nil && s.At.Second() != 0 {
+			return true
+		}
+	}
+	for _, t := range fw.Transitions {
+		if (t.FromSolar == nil && t.From.Second() != 0) || (t.UpToSolar == nil && t.UpTo.Second() != 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatVersion returns the version of the Simple Timed Wallpaper format
+// that is needed for writing this timed wallpaper. This is the version of
+// the timed wallpaper, unless it uses features from a newer version.
+func (fw *FatWallpaper) formatVersion() string {
+	required := simpleTimedWallpaperFormatVersion
+	if fw.usesSeconds() {
+		required = simpleTimedWallpaperSecondsVersion
+	}
+	if fw.usesSunPosition() || fw.usesConditions() || fw.usesCron() || fw.usesEasing() || fw.usesColorSpaces() || len(fw.Includes) > 0 || fw.usesFormatExtensions() {
+		required = simpleTimedWallpaperExtendedVersion
+	}
+	if newerVersion(required, fw.Version) {
+		return required
+	}
+	return fw.Version
+}
+
 func (fw *FatWallpaper) AddStatic(at time.Time, filename string) {
 	if fw.GNOME {
 		panic("not implemented for GNOME timed wallpaper")
 	}
 	var s Static
 	s.At = at
-	if len(fw.Format) > 0 {
-		s.Filename = fmt.Sprintf(fw.Format, filename)
-	} else {
-		s.Filename = filename
-	}
+	s.Filename = applyFormat(fw.expandedFormat(), filename)
 	fw.Statics = append(fw.Statics, &s)
 }
 
@@ -117,13 +288,9 @@ func (fw *FatWallpaper) AddTransition(from, upto time.Time, fromFilename, toFile
 	var t Transition
 	t.From = from
 	t.UpTo = upto
-	if len(fw.Format) > 0 {
-		t.FromFilename = fmt.Sprintf(fw.Format, fromFilename)
-		t.ToFilename = fmt.Sprintf(fw.Format, toFilename)
-	} else {
-		t.FromFilename = fromFilename
-		t.ToFilename = toFilename
-	}
+	format := fw.expandedFormat()
+	t.FromFilename = applyFormat(format, fromFilename)
+	t.ToFilename = applyFormat(format, toFilename)
 	if len(transitionType) == 0 {
 		t.Type = "overlay"
 	} else {
@@ -132,6 +299,38 @@ func (fw *FatWallpaper) AddTransition(from, upto time.Time, fromFilename, toFile
 	fw.Transitions = append(fw.Transitions, &t)
 }
 
+// splitEvent splits an event line, without the leading "@", into the
+// timestamps and the filenames. The timestamps end at the first colon that
+// is not a part of a HH:MM or HH:MM:SS timestamp.
+func splitEvent(line string) (string, string, bool) {
+	for i := 0; i < len(line); i++ {
+		if i == 0 || !isDigit(line[i-1]) {
+			if n := clockLength(line[i:]); n > 0 {
+				i += n - 1
+				continue
+			}
+		}
+		if line[i] == ':' {
+			return line[:i], line[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// parseTimestamp parses a clock time on the form HH:MM or HH:MM:SS, or a
+// time that is relative to a solar event, like "sunrise-30m"
+func parseTimestamp(s string) (time.Time, *SolarTime, error) {
+	s = strings.TrimSpace(s)
+	if t, err := parseClock(s); err == nil {
+		return t, nil, nil
+	}
+	st, err := ParseSolarTime(s)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return time.Time{}, st, nil
+}
+
 func ParseSTW(filename string) (*FatWallpaper, error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -142,11 +341,23 @@ func ParseSTW(filename string) (*FatWallpaper, error) {
 
 // DataToSimple converts from the contents of a Simple Timed Wallpaper file to
 // a Wallpaper structs. The given path is used in the error messages
-// and for setting stw.Path.
+// and for setting stw.Path. Included files are relative to the given path.
 func DataToSimple(path string, data []byte) (*FatWallpaper, error) {
+	return dataToSimple(path, data, nil)
+}
+
+// dataToSimple converts from the contents of a Simple Timed Wallpaper file
+// to a Wallpaper struct, where the chain is the absolute paths of the files
+// that include this file
+func dataToSimple(path string, data []byte, chain []string) (*FatWallpaper, error) {
 	var ts []*Transition
 	var ss []*Static
+	var includes []*Include
+	var formats []string           // from the format fields, in order
+	var tsFormats, ssFormats []int // the format field that each event comes after, or -1
+	variables := make(map[string]string)
 	parsed := make(map[string]string)
+	var section *Condition // the condition of the current section, if any
 	for lineCount, byteLine := range bytes.Split(data, []byte("\n")) {
 		trimmed := strings.TrimSpace(string(byteLine))
 		if strings.HasPrefix(trimmed, "#") {
@@ -158,55 +369,100 @@ func DataToSimple(path string, data []byte) (*FatWallpaper, error) {
 		} else if len(trimmed) == 0 {
 			continue
 		}
-		if strings.HasPrefix(trimmed, "@") {
-			if len(trimmed) > 6 && (trimmed[6] == ' ' || trimmed[6] == '-') && (trimmed[7] != ':') {
-				if strings.Count(trimmed, "-") < 1 {
-					return nil, fmt.Errorf("could not parse %s (no dash), line %d: %s", path, lineCount, trimmed)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			// A section, where the events only apply to some days
+			name := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			if name == "daily" {
+				section = nil
+				continue
+			}
+			c, err := ParseCondition(name)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s (%s), line %d: %s", path, err, lineCount, trimmed)
+			}
+			section = c
+		} else if strings.HasPrefix(trimmed, "@") {
+			// Split the line into the timestamps and the filenames
+			timestamps, filenames, ok := splitEvent(trimmed[1:])
+			if !ok {
+				return nil, fmt.Errorf("could not parse %s (missing colon), line %d: %s", path, lineCount, trimmed)
+			}
+			// The timestamps may start with a condition, like "sat,sun"
+			condition, timestamps, err := splitCondition(timestamps)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s (%s), line %d: %s", path, err, lineCount, trimmed)
+			}
+			condition = section.with(condition)
+			if timestamps == "" && condition != nil && condition.Dates != nil {
+				// Events for a date without a time start at midnight
+				timestamps = "00:00"
+			}
+			if strings.HasPrefix(timestamps, "cron ") {
+				// A static image that is shown according to a cron expression
+				spec, err := ParseCron(timestamps[len("cron "):])
+				if err != nil {
+					return nil, fmt.Errorf("could not parse %s (%s), line %d: %s", path, err, lineCount, trimmed)
 				}
-				fields := strings.SplitN(trimmed[1:], "-", 2)
-				time1 := strings.TrimSpace(fields[0])
-				if strings.Count(fields[1], ":") < 2 {
-					return nil, fmt.Errorf("could not parse %s (missing colon), line %d: %s", path, lineCount, trimmed)
+				if strings.Contains(filenames, "..") {
+					return nil, fmt.Errorf("could not parse %s (only static images may use cron expressions), line %d: %s", path, lineCount, trimmed)
+				}
+				ss = append(ss, &Static{At: clockTime(0), Filename: strings.TrimSpace(filenames), Condition: condition, Cron: spec})
+				ssFormats = append(ssFormats, len(formats)-1)
+			} else if _, _, err := parseTimestamp(timestamps); err != nil && (strings.Contains(timestamps, "..") || strings.Contains(timestamps, "-")) {
+				// Solar times may contain dashes, so ".." is used between them
+				separator := "-"
+				if strings.Contains(timestamps, "..") {
+					separator = ".."
 				}
-				fields = strings.SplitN(fields[1], ":", 3)
-				time2 := strings.TrimSpace(fields[0] + ":" + fields[1])
-				filenames := fields[2]
+				fields := strings.SplitN(timestamps, separator, 2)
+				time1 := strings.TrimSpace(fields[0])
+				time2 := strings.TrimSpace(fields[1])
 				if !strings.Contains(filenames, "..") {
 					return nil, fmt.Errorf("could not parse %s (missing \"..\"), line %d: %s", path, lineCount, trimmed)
 				}
 				fields = strings.SplitN(filenames, "..", 2)
 				filename1 := strings.TrimSpace(fields[0])
 				filename2 := strings.TrimSpace(fields[1])
-				transitionType := "overlay"
+				t := &Transition{FromFilename: filename1, Type: "overlay", Condition: condition}
 				if strings.Contains(filename2, "|") {
 					fields := strings.SplitN(filename2, "|", 2)
 					filename2 = strings.TrimSpace(fields[0])
-					transitionType = strings.TrimSpace(fields[1])
+					if err := t.parseOptions(fields[1]); err != nil {
+						return nil, fmt.Errorf("could not parse %s (%s), line %d: %s", path, err, lineCount, trimmed)
+					}
 				}
-				//fmt.Println("TRANSITION", time1, "|", time2, "|", filename1, "|", filename2, "|", transitionType)
-				t1, err := time.Parse("15:04", time1)
+				t.ToFilename = filename2
+				//fmt.Println("TRANSITION", time1, "|", time2, "|", filename1, "|", filename2, "|", t.Type)
+				t1, solar1, err := parseTimestamp(time1)
 				if err != nil {
 					return nil, fmt.Errorf("could not parse %s (time), line %d: %s", path, lineCount, trimmed)
 				}
-				t2, err := time.Parse("15:04", time2)
+				t2, solar2, err := parseTimestamp(time2)
 				if err != nil {
 					return nil, fmt.Errorf("could not parse %s (time), line %d: %s", path, lineCount, trimmed)
 				}
-				ts = append(ts, &Transition{t1, t2, filename1, filename2, transitionType})
+				t.From, t.FromSolar = t1, solar1
+				t.UpTo, t.UpToSolar = t2, solar2
+				ts = append(ts, t)
+				tsFormats = append(tsFormats, len(formats)-1)
 			} else {
-				if strings.Count(trimmed, ":") < 2 {
-					return nil, fmt.Errorf("could not parse %s (missing colon), line %d: %s", path, lineCount, trimmed)
-				}
-				fields := strings.SplitN(trimmed[1:], ":", 3)
-				time1 := strings.TrimSpace(fields[0] + ":" + fields[1])
-				filename := strings.TrimSpace(fields[2])
+				time1 := strings.TrimSpace(timestamps)
+				filename := strings.TrimSpace(filenames)
 				//fmt.Println("STATIC", time1, "|", filename)
-				t1, err := time.Parse("15:04", time1)
+				t1, solar1, err := parseTimestamp(time1)
 				if err != nil {
 					return nil, fmt.Errorf("could not parse %s (time), line %d: %s", path, lineCount, trimmed)
 				}
-				ss = append(ss, &Static{t1, filename})
+				ss = append(ss, &Static{At: t1, Filename: filename, Solar: solar1, Condition: condition})
+				ssFormats = append(ssFormats, len(formats)-1)
+			}
+		} else if strings.HasPrefix(trimmed, "set ") {
+			// A variable, like "set theme = mojave"
+			name, value, err := parseSet(trimmed, variables)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s (%s), line %d: %s", path, err, lineCount, trimmed)
 			}
+			variables[name] = value
 		} else if strings.Contains(trimmed, ":") {
 			//fmt.Println("FIELD", trimmed)
 			if strings.Count(trimmed, ":") < 1 {
@@ -215,6 +471,21 @@ func DataToSimple(path string, data []byte) (*FatWallpaper, error) {
 			fields := strings.SplitN(trimmed, ":", 2)
 			key := strings.TrimSpace(fields[0])
 			value := strings.TrimSpace(fields[1])
+			if key == "include" {
+				// There may be several include fields
+				inc, err := ParseInclude(value)
+				if err != nil {
+					return nil, fmt.Errorf("could not parse %s (%s), line %d: %s", path, err, lineCount, trimmed)
+				}
+				includes = append(includes, inc)
+				continue
+			}
+			if key == "format" {
+				// There may be several format fields, where each one is used
+				// for the events that come after it
+				formats = append(formats, value)
+				continue
+			}
 			parsed[key] = value
 		} else {
 			return nil, fmt.Errorf("could not parse %s (invalid syntax), line %d: %s", path, lineCount, trimmed)
@@ -224,18 +495,135 @@ func DataToSimple(path string, data []byte) (*FatWallpaper, error) {
 	if !ok {
 		return nil, fmt.Errorf("could not find stw field in %s", path)
 	}
-	name := parsed["name"]     // optional
-	format := parsed["format"] // optional
+	name := parsed["name"] // optional
+	format := ""           // optional
+	if len(formats) > 0 {
+		format = formats[0]
+	}
 
 	stw := NewSimple(version, name, format)
 	stw.Path = path
-	for _, t := range ts {
-		// Adding transitions in a way that make sure the format string is used when interpreting the filenames
-		stw.AddTransition(t.From, t.UpTo, t.FromFilename, t.ToFilename, t.Type)
+	if len(variables) > 0 {
+		stw.Variables = variables
 	}
-	for _, s := range ss {
-		// Adding static images in a way that make sure the format string is used when interpreting the filenames
-		stw.AddStatic(s.At, s.Filename)
+	for key, value := range parsed { // optional
+		if IsMetadataField(key) {
+			stw.SetMeta(key, value)
+		} else if locale, ok := parseNameKey(key); ok {
+			stw.SetNameFor(locale, value)
+		}
+	}
+	// Expand the variables in the format fields
+	for i, f := range formats {
+		expanded, err := expandVariables(f, variables)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the format field in %s: %s", path, err)
+		}
+		formats[i] = expanded
+	}
+	// eventFilename returns the filename for the given name of an image, in
+	// an event that comes after the given format field. Events that come
+	// before all format fields use the first one.
+	eventFilename := func(name string, formatIndex int) (string, error) {
+		expanded, err := expandVariables(name, variables)
+		if err != nil {
+			return "", fmt.Errorf("could not parse %s: %s", path, err)
+		}
+		if len(formats) == 0 {
+			return expanded, nil
+		}
+		if formatIndex < 0 {
+			formatIndex = 0
+		}
+		return applyFormat(formats[formatIndex], expanded), nil
+	}
+	if tz, ok := parsed["tz"]; ok { // optional
+		if err := stw.SetTimeZone(tz); err != nil {
+			return nil, fmt.Errorf("could not use the time zone in %s: %s", path, err)
+		}
+	}
+	if holidays, ok := parsed["holidays"]; ok { // optional
+		if err := stw.SetHolidays(holidays); err != nil {
+			return nil, fmt.Errorf("could not read the holidays for %s: %s", path, err)
+		}
+	}
+	if hemisphere, ok := parsed["hemisphere"]; ok { // optional
+		if hemisphere != "north" && hemisphere != "south" {
+			return nil, fmt.Errorf("the hemisphere field in %s must be north or south: %s", path, hemisphere)
+		}
+		stw.Hemisphere = hemisphere
+	}
+	if dither, ok := parsed["dither"]; ok { // optional
+		if err := checkDither(dither); err != nil {
+			return nil, fmt.Errorf("could not use the dither field in %s: %s", path, err)
+		}
+		stw.Dither = dither
+	}
+	if blendDays, ok := parsed["blend-days"]; ok { // optional
+		days, err := strconv.Atoi(blendDays)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("could not parse the blend-days field in %s: %s", path, blendDays)
+		}
+		stw.BlendDays = days
+	}
+	for _, field := range []string{"latitude", "longitude"} { // optional
+		value, ok := parsed[field]
+		if !ok {
+			continue
+		}
+		degrees, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the %s field in %s: %s", field, path, value)
+		}
+		if field == "latitude" {
+			stw.Latitude = degrees
+		} else {
+			stw.Longitude = degrees
+		}
+	}
+	for i, t := range ts {
+		// Use the format string when interpreting the filenames
+		var err error
+		if t.FromFilename, err = eventFilename(t.FromFilename, tsFormats[i]); err != nil {
+			return nil, err
+		}
+		if t.ToFilename, err = eventFilename(t.ToFilename, tsFormats[i]); err != nil {
+			return nil, err
+		}
+		stw.Transitions = append(stw.Transitions, t)
+	}
+	for i, s := range ss {
+		// Use the format string when interpreting the filename
+		var err error
+		if s.Filename, err = eventFilename(s.Filename, ssFormats[i]); err != nil {
+			return nil, err
+		}
+		stw.Statics = append(stw.Statics, s)
+	}
+	_, hasLatitude := parsed["latitude"]
+	_, hasLongitude := parsed["longitude"]
+	hasCoordinates := hasLatitude && hasLongitude
+	if len(chain) == 0 {
+		// Start the include chain with this file
+		if absPath, err := filepath.Abs(path); err == nil {
+			chain = []string{absPath}
+		}
+	}
+	for _, inc := range includes {
+		if err := stw.include(inc, chain); err != nil {
+			return nil, fmt.Errorf("%s, included from %s", err, path)
+		}
+		// The latitude and longitude may be given by the included file
+		hasCoordinates = hasCoordinates || stw.Latitude != 0 || stw.Longitude != 0
+	}
+	if stw.usesHolidays() && stw.Holidays == nil && stw.HolidaysFile == "" {
+		return nil, fmt.Errorf("%s has events for holidays, but no holidays field", path)
+	}
+	if stw.usesSunPosition() {
+		if !hasCoordinates {
+			return nil, fmt.Errorf("%s has events that depend on the position of the sun, but no latitude and longitude fields", path)
+		}
+		stw.updateSolarTimes()
 	}
 	//fmt.Println(stw)
 	return stw, nil